@@ -0,0 +1,419 @@
+package browser
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/persist"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cacheDirName   = "cache"
+	cacheIndexName = "index.json"
+	partExt        = ".part"
+	// retries is how many times a download that failed in a way that may not happen again is retried, waiting
+	// retryBackoff and then twice as long before each further retry. A download that stopped part way is resumed.
+	retries          = 4
+	retryBackoff     = time.Second
+	defaultCacheSize = 4 << 10
+)
+
+// downloadCache keeps downloaded files keyed by their url and the ETag and Last-Modified the server sent, so a
+// download is only fetched again once the server has a different version of it. Downloads are streamed to a part
+// file first, which is resumed with a range request when the transfer stops part way.
+type downloadCache struct {
+	mutex sync.Mutex
+	// locks holds a mutex per url so the same file is never downloaded twice at once
+	locks sync.Map
+}
+
+type cacheIndex struct {
+	Entries []*cacheEntry `json:"Entries"`
+}
+
+type cacheEntry struct {
+	Key          string    `json:"Key"`
+	URL          string    `json:"URL"`
+	Name         string    `json:"Name"`
+	ETag         string    `json:"ETag,omitempty"`
+	LastModified string    `json:"LastModified,omitempty"`
+	Size         int64     `json:"Size"`
+	LastUsed     time.Time `json:"LastUsed"`
+	// file is where the download is, uncached downloads are left in their part file
+	file     string
+	uncached bool
+}
+
+// partMeta is the validators of a part file, which a resumed download must match
+type partMeta struct {
+	ETag         string `json:"ETag,omitempty"`
+	LastModified string `json:"LastModified,omitempty"`
+	Name         string `json:"Name"`
+}
+
+var cache = &downloadCache{}
+
+func cacheDir() string {
+	return path.Join(config.PWD, cacheDirName)
+}
+
+func cacheLimit() int64 {
+	if mb := config.Get().CacheSize; mb > 0 {
+		return int64(mb) << 20
+	}
+	return defaultCacheSize << 20
+}
+
+// get returns the source's download from the cache, requesting url if it is not cached or the server has a newer one.
+// The source is what the download is cached as, url is where it is currently downloaded from.
+func (c *downloadCache) get(ctx context.Context, source string, url string, progress func(written, total int64)) (e *cacheEntry, err error) {
+	l, _ := c.locks.LoadOrStore(source, &sync.Mutex{})
+	l.(*sync.Mutex).Lock()
+	defer l.(*sync.Mutex).Unlock()
+
+	if err = os.MkdirAll(cacheDir(), 0777); err != nil {
+		return
+	}
+	var (
+		cached = c.lookup(source)
+		retry  bool
+	)
+	if progress == nil {
+		progress = func(int64, int64) {}
+	}
+	for attempt := 0; ; attempt++ {
+		if e, retry, err = c.fetch(ctx, source, url, cached, progress); err == nil || !retry || attempt >= retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(retryBackoff << attempt):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return
+}
+
+// fetch resumes the source's part file if there is one, otherwise it asks for the url only if it differs from cached.
+// retry is true when the download failed in a way that resuming it may fix.
+func (c *downloadCache) fetch(ctx context.Context, source string, url string, cached *cacheEntry, progress func(written, total int64)) (e *cacheEntry, retry bool, err error) {
+	var (
+		part   = c.partFile(source)
+		meta   partMeta
+		offset int64
+		total  int64 = -1
+		req    *http.Request
+		resp   *http.Response
+		f      *os.File
+	)
+	if req, err = newRequest(ctx, url); err != nil {
+		return
+	}
+	if isGitHubAsset(url) {
+		req.Header.Set("Accept", "application/octet-stream")
+	}
+	if meta, offset = c.readPart(source); offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if meta.ETag != "" {
+			req.Header.Set("If-Range", meta.ETag)
+		} else {
+			req.Header.Set("If-Range", meta.LastModified)
+		}
+	} else if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	if resp, err = do(req); err != nil {
+		return nil, err != ErrOffline, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var body io.Reader = resp.Body
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if cached == nil {
+			return nil, false, fmt.Errorf("failed to download the mod's source at %s", url)
+		}
+		progress(cached.Size, cached.Size)
+		return cached, false, c.touch(cached)
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			c.removePart(source)
+			return nil, true, fmt.Errorf("%s did not resume at the requested offset", url)
+		}
+		if f, err = os.OpenFile(part, os.O_WRONLY|os.O_APPEND, 0666); err != nil {
+			return
+		}
+		if resp.ContentLength >= 0 {
+			total = offset + resp.ContentLength
+		}
+	case http.StatusOK:
+		var br *bufio.Reader
+		if br, err = checkNotPage(url, resp); err != nil {
+			return
+		}
+		head, _ := br.Peek(512)
+		body = br
+		meta = partMeta{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Name:         responseFileName(resp, head),
+		}
+		if err = c.writePart(source, meta); err != nil {
+			return
+		}
+		if f, err = os.Create(part); err != nil {
+			return
+		}
+		offset = 0
+		total = resp.ContentLength
+	case http.StatusRequestedRangeNotSatisfiable:
+		c.removePart(source)
+		return nil, true, fmt.Errorf("%s cannot be resumed", url)
+	default:
+		// Server errors and rate limiting may pass
+		retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, fmt.Errorf("failed to download the mod's source at %s: %s", url, resp.Status)
+	}
+
+	progress(offset, total)
+	_, err = io.Copy(&progressWriter{w: f, written: offset, total: total, progress: progress}, body)
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	if meta.ETag == "" && meta.LastModified == "" {
+		// Without validators there is no telling whether the download changed, so it is not cached
+		_ = os.Remove(part + ".json")
+		return &cacheEntry{URL: source, Name: meta.Name, file: part, uncached: true}, false, nil
+	}
+	e, err = c.add(source, meta, part)
+	return
+}
+
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	total    int64
+	progress func(written, total int64)
+}
+
+func (w *progressWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.Write(p)
+	w.written += int64(n)
+	w.progress(w.written, w.total)
+	return
+}
+
+// lookup returns the url's cached download if its file still exists
+func (c *downloadCache) lookup(url string) *cacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	idx := c.load()
+	for _, e := range idx.Entries {
+		if e.URL == url {
+			if _, err := os.Stat(e.file); err == nil {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (c *downloadCache) touch(e *cacheEntry) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	idx := c.load()
+	for _, ie := range idx.Entries {
+		if ie.Key == e.Key {
+			ie.LastUsed = time.Now()
+		}
+	}
+	return c.save(idx)
+}
+
+// remove drops the url's cached download and any part of it
+func (c *downloadCache) remove(url string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	idx := c.load()
+	entries := idx.Entries[:0]
+	for _, e := range idx.Entries {
+		if e.URL == url {
+			_ = os.Remove(e.file)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	idx.Entries = entries
+	_ = c.save(idx)
+	c.removePart(url)
+}
+
+// add moves the finished part file into the cache, replacing older versions of the url, and evicts the least
+// recently used downloads that no longer fit
+func (c *downloadCache) add(url string, meta partMeta, part string) (e *cacheEntry, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var (
+		idx = c.load()
+		fi  os.FileInfo
+	)
+	e = &cacheEntry{
+		Key:          cacheKey(url, meta.ETag, meta.LastModified),
+		URL:          url,
+		Name:         meta.Name,
+		ETag:         meta.ETag,
+		LastModified: meta.LastModified,
+		LastUsed:     time.Now(),
+	}
+	e.file = path.Join(cacheDir(), e.Key)
+	if err = os.Rename(part, e.file); err != nil {
+		return nil, err
+	}
+	_ = os.Remove(part + ".json")
+	if fi, err = os.Stat(e.file); err != nil {
+		return nil, err
+	}
+	e.Size = fi.Size()
+
+	entries := []*cacheEntry{e}
+	for _, ie := range idx.Entries {
+		if ie.URL == url || ie.Key == e.Key {
+			if ie.Key != e.Key {
+				_ = os.Remove(ie.file)
+			}
+			continue
+		}
+		entries = append(entries, ie)
+	}
+	idx.Entries = c.evict(entries, e)
+	return e, c.save(idx)
+}
+
+// evict removes the least recently used entries, other than keep, until the cache fits its limit
+func (c *downloadCache) evict(entries []*cacheEntry, keep *cacheEntry) []*cacheEntry {
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+	kept := make([]*cacheEntry, 0, len(entries))
+	for _, e := range entries {
+		if total > cacheLimit() && e != keep {
+			total -= e.Size
+			_ = os.Remove(e.file)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+func (c *downloadCache) load() *cacheIndex {
+	idx := &cacheIndex{}
+	if b, err := ioutil.ReadFile(path.Join(cacheDir(), cacheIndexName)); err == nil {
+		_ = json.Unmarshal(b, idx)
+	}
+	for _, e := range idx.Entries {
+		e.file = path.Join(cacheDir(), e.Key)
+	}
+	return idx
+}
+
+func (c *downloadCache) save(idx *cacheIndex) error {
+	b, err := json.MarshalIndent(idx, "", "\t")
+	if err != nil {
+		return err
+	}
+	return persist.WriteFile(path.Join(cacheDir(), cacheIndexName), b)
+}
+
+func (c *downloadCache) partFile(url string) string {
+	return path.Join(cacheDir(), cacheKey(url)+partExt)
+}
+
+// readPart returns the part file's validators and size, or an offset of 0 when it cannot be resumed
+func (c *downloadCache) readPart(url string) (meta partMeta, offset int64) {
+	var (
+		part = c.partFile(url)
+		fi   os.FileInfo
+		b    []byte
+		err  error
+	)
+	if fi, err = os.Stat(part); err != nil {
+		return
+	}
+	if b, err = ioutil.ReadFile(part + ".json"); err != nil || json.Unmarshal(b, &meta) != nil {
+		return partMeta{}, 0
+	}
+	if meta.ETag == "" && meta.LastModified == "" {
+		return partMeta{}, 0
+	}
+	return meta, fi.Size()
+}
+
+func (c *downloadCache) writePart(url string, meta partMeta) error {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.partFile(url)+".json", b, 0666)
+}
+
+func (c *downloadCache) removePart(url string) {
+	part := c.partFile(url)
+	_ = os.Remove(part + ".json")
+	_ = os.Remove(part)
+}
+
+func cacheKey(s ...string) string {
+	h := sha256.Sum256([]byte(strings.Join(s, "\n")))
+	return hex.EncodeToString(h[:])
+}
+
+// linkOrCopy places the cached file at to, falling back to a copy when it cannot be hard linked
+func linkOrCopy(from string, to string) (err error) {
+	var in, out *os.File
+	_ = os.Remove(to)
+	if err = os.Link(from, to); err == nil {
+		return
+	}
+	if in, err = os.Open(from); err != nil {
+		return
+	}
+	defer func() { _ = in.Close() }()
+	if out, err = os.Create(to); err != nil {
+		return
+	}
+	defer func() { _ = out.Close() }()
+	_, err = io.Copy(out, in)
+	return
+}
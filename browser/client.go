@@ -0,0 +1,158 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	connectTimeout = 15 * time.Second
+	// responseTimeout is how long a server has to start responding once a request is sent
+	responseTimeout = 30 * time.Second
+	// readTimeout is how long a response's body may go without sending anything
+	readTimeout = 60 * time.Second
+)
+
+var (
+	ErrOffline = errors.New("offline mode is on, turn it off in File > Configure to use the network")
+
+	clientMutex sync.Mutex
+	client      *http.Client
+	clientProxy string
+)
+
+// httpClient is the client every request is sent with, rebuilt whenever the configured proxy changes
+func httpClient() (*http.Client, error) {
+	clientMutex.Lock()
+	defer clientMutex.Unlock()
+	proxy := config.Get().Proxy
+	if client != nil && proxy == clientProxy {
+		return client, nil
+	}
+	var proxyFunc = http.ProxyFromEnvironment
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("the configured proxy %s is not a valid url", proxy)
+		}
+		proxyFunc = http.ProxyURL(u)
+	}
+	client = &http.Client{
+		Transport: &http.Transport{
+			Proxy: proxyFunc,
+			DialContext: (&net.Dialer{
+				Timeout:   connectTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   connectTimeout,
+			ResponseHeaderTimeout: responseTimeout,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+	clientProxy = proxy
+	return client, nil
+}
+
+func newRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "MoogleModManager/"+Version+" (+https://github.com/KiameV/ffprModManager)")
+	return req, nil
+}
+
+// do sends the request, failing fast when offline. The response's body is cancelled once it stops sending for
+// readTimeout.
+func do(req *http.Request) (resp *http.Response, err error) {
+	var c *http.Client
+	if config.Get().Offline {
+		return nil, ErrOffline
+	}
+	if c, err = httpClient(); err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	if resp, err = c.Do(req.WithContext(ctx)); err != nil {
+		cancel()
+		return
+	}
+	resp.Body = &idleTimeoutBody{
+		ReadCloser: resp.Body,
+		cancel:     cancel,
+		timer:      time.AfterFunc(readTimeout, cancel),
+	}
+	return
+}
+
+func get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := newRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return do(req)
+}
+
+// GetJSON decodes the json the url responds with into v, sending the header along with the request
+func GetJSON(ctx context.Context, url string, header http.Header, v interface{}) (err error) {
+	var (
+		req  *http.Request
+		resp *http.Response
+	)
+	if req, err = newRequest(ctx, url); err != nil {
+		return
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Accept", "application/json")
+	if resp, err = do(req); err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// StatusError is a response whose status is not OK
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request to %s failed: %s", e.URL, e.Status)
+}
+
+type idleTimeoutBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	timer  *time.Timer
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	b.timer.Reset(readTimeout)
+	return
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -1,30 +1,76 @@
 package browser
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path"
 	"strings"
 )
 
-func Download(url, toDir string) (string, error) {
+// Download saves the url's content in toDir. The content is streamed to disk through the download cache, so a
+// download that did not change since it was last fetched is not fetched again.
+func Download(url, toDir string) (file string, err error) {
+	return DownloadContext(context.Background(), url, toDir, nil)
+}
+
+// DownloadContext is Download that stops once ctx is done and reports the bytes written so far. total is -1 while
+// the size is not known. A download that was stopped is resumed by the next download of the url.
+func DownloadContext(ctx context.Context, url, toDir string, progress func(written, total int64)) (file string, err error) {
 	var (
-		buf, name, err = download(url)
-		out            *os.File
-		file           = path.Join(toDir, name)
+		e      *cacheEntry
+		source = url
 	)
-	// Create the file
-	if out, err = os.Create(file); err != nil {
+	if url, err = resolve(ctx, url); err != nil {
+		return
+	}
+	if e, err = cache.get(ctx, source, url, progress); err != nil {
+		return "", err
+	}
+	file = path.Join(toDir, e.Name)
+	if e.uncached {
+		_ = os.Remove(file)
+		if err = os.Rename(e.file, file); err != nil {
+			err = linkOrCopy(e.file, file)
+			_ = os.Remove(e.file)
+		}
+		if err != nil {
+			return "", err
+		}
+		return
+	}
+	if err = linkOrCopy(e.file, file); err != nil {
 		return "", err
 	}
-	defer func() { _ = out.Close() }()
+	return
+}
 
-	// Write the body to file
-	_, err = io.Copy(out, buf)
-	return file, err
+// DownloadFromMirrors downloads the first of the sources that succeeds and passes verify, which may be nil. A source
+// whose download fails verify is removed from the cache. source is the mirror the file was downloaded from.
+func DownloadFromMirrors(ctx context.Context, sources []string, toDir string, verify func(file string) error, progress func(written, total int64)) (file string, source string, err error) {
+	var errs []string
+	if len(sources) == 0 {
+		return "", "", fmt.Errorf("there are no sources to download from")
+	}
+	for _, source = range sources {
+		if file, err = DownloadContext(ctx, source, toDir, progress); err == nil && verify != nil {
+			if err = verify(file); err != nil {
+				_ = os.Remove(file)
+				cache.remove(source)
+			}
+		}
+		if err != nil && ctx.Err() != nil {
+			return "", "", ctx.Err()
+		}
+		if err == nil || err == ErrOffline {
+			return
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", source, err))
+	}
+	return "", "", fmt.Errorf("failed to download from every source:\n%s", strings.Join(errs, "\n"))
 }
 
 func DownloadAsString(url string) (string, error) {
@@ -46,26 +92,27 @@ func DownloadAsBytes(url string) ([]byte, error) {
 func download(url string) (buf *bytes.Buffer, name string, err error) {
 	var (
 		resp *http.Response
-		sp   = strings.Split(url, "/")
+		r    *bufio.Reader
 	)
-	if resp, err = http.Get(url); err != nil {
+	if url, err = resolve(context.Background(), url); err != nil {
 		return
 	}
-	defer func() { resp.Body.Close() }()
-
-	if len(sp) == 0 {
-		err = fmt.Errorf("invalid url: %s", url)
+	if resp, err = get(context.Background(), url); err != nil {
 		return
 	}
+	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != 200 {
 		err = fmt.Errorf("failed to download the mod's source at %s", url)
 		return
 	}
-	defer resp.Body.Close()
 
+	if r, err = checkNotPage(url, resp); err != nil {
+		return
+	}
+	head, _ := r.Peek(512)
+	name = responseFileName(resp, head)
 	buf = new(bytes.Buffer)
-	_, err = buf.ReadFrom(resp.Body)
-	name = sp[len(sp)-1]
+	_, err = buf.ReadFrom(r)
 	return
 }
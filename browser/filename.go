@@ -0,0 +1,94 @@
+package browser
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const maxFileNameLength = 200
+
+var (
+	// sniffedExts are the extensions of the formats a download is expected to be, by their first bytes
+	sniffedExts = []struct {
+		magic []byte
+		ext   string
+	}{
+		{magic: []byte("PK\x03\x04"), ext: ".zip"},
+		{magic: []byte("PK\x05\x06"), ext: ".zip"},
+		{magic: []byte("7z\xbc\xaf\x27\x1c"), ext: ".7z"},
+		{magic: []byte("Rar!\x1a\x07"), ext: ".rar"},
+		{magic: []byte("\x1f\x8b"), ext: ".gz"},
+		{magic: []byte("\xfd7zXZ\x00"), ext: ".xz"},
+	}
+	reservedNames = map[string]bool{
+		"CON": true, "PRN": true, "AUX": true, "NUL": true,
+		"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+		"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+	}
+)
+
+// responseFileName names a download by its Content-Disposition, otherwise by the last segment of the url it was
+// finally served from. When that name has no extension, one is added from the content's first bytes or its type.
+func responseFileName(resp *http.Response, head []byte) string {
+	var name string
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		name = params["filename"]
+	}
+	if name == "" && resp.Request != nil && resp.Request.URL != nil {
+		name = path.Base(resp.Request.URL.Path)
+		if name == "/" || name == "." {
+			name = ""
+		}
+	}
+	name = sanitizeFileName(name)
+	if path.Ext(name) == "" {
+		name += sniffExt(head, resp.Header.Get("Content-Type"))
+	}
+	return name
+}
+
+func sniffExt(head []byte, contentType string) string {
+	for _, s := range sniffedExts {
+		if bytes.HasPrefix(head, s.magic) {
+			return s.ext
+		}
+	}
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil && mt != "application/octet-stream" {
+		if exts, err := mime.ExtensionsByType(mt); err == nil && len(exts) > 0 {
+			return exts[0]
+		}
+	}
+	return ""
+}
+
+// sanitizeFileName makes the name safe to create on Windows, macOS and Linux
+func sanitizeFileName(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < 32 || r == 127 || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+	if name == "" {
+		return "download"
+	}
+	base := strings.ToUpper(strings.TrimSuffix(name, path.Ext(name)))
+	if reservedNames[base] {
+		name = "_" + name
+	}
+	if len(name) > maxFileNameLength {
+		ext := path.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:maxFileNameLength-len(ext)], "") + ext
+	}
+	return name
+}
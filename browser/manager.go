@@ -0,0 +1,342 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// progressInterval is how often a DownloadManager reports progress
+const progressInterval = 250 * time.Millisecond
+
+// DownloadManager downloads items on a bounded pool of workers. Pausing stops the transfers in flight, which resume
+// from where they stopped once the manager is resumed.
+type DownloadManager struct {
+	workers int
+	mutex   sync.Mutex
+	// pause is closed while the manager is paused and resume while it is not
+	pause  chan struct{}
+	resume chan struct{}
+}
+
+type DownloadItem struct {
+	Name    string
+	Sources []string
+	// Verify, if set, rejects a downloaded file so the next source is tried
+	Verify func(file string) error
+	// File and Source are set once the item is downloaded
+	File   string
+	Source string
+}
+
+type ItemProgress struct {
+	Name    string
+	Written int64
+	// Total is -1 while it is not known
+	Total int64
+	// Speed is in bytes per second
+	Speed float64
+	Done  bool
+}
+
+type DownloadProgress struct {
+	Items   []ItemProgress
+	Written int64
+	// Total is -1 while the total of any item is not known
+	Total int64
+	Speed float64
+	// ETA is -1 while it is not known
+	ETA    time.Duration
+	Paused bool
+}
+
+func NewDownloadManager(workers int) *DownloadManager {
+	if workers < 1 {
+		workers = 1
+	}
+	m := &DownloadManager{
+		workers: workers,
+		pause:   make(chan struct{}),
+		resume:  make(chan struct{}),
+	}
+	close(m.resume)
+	return m
+}
+
+func (m *DownloadManager) Pause() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !isClosed(m.pause) {
+		close(m.pause)
+		m.resume = make(chan struct{})
+	}
+}
+
+func (m *DownloadManager) Resume() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !isClosed(m.resume) {
+		close(m.resume)
+		m.pause = make(chan struct{})
+	}
+}
+
+func (m *DownloadManager) IsPaused() bool {
+	pause, _ := m.channels()
+	return isClosed(pause)
+}
+
+// Download downloads every item into its own dir under toDir, reporting progress every progressInterval and once
+// every item is done. The first item to fail cancels the rest.
+func (m *DownloadManager) Download(ctx context.Context, toDir string, items []*DownloadItem, progress func(DownloadProgress)) (err error) {
+	var (
+		t      = newTracker(items)
+		queue  = make(chan int)
+		errs   = make(chan error, len(items))
+		wg     sync.WaitGroup
+		ticker = time.NewTicker(progressInterval)
+	)
+	if progress == nil {
+		progress = func(DownloadProgress) {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer ticker.Stop()
+
+	for w := 0; w < m.workers && w < len(items); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if dErr := m.download(ctx, path.Join(toDir, strconv.Itoa(i)), items[i], t.item(i)); dErr != nil {
+					errs <- fmt.Errorf("failed to download %s: %w", items[i].Name, dErr)
+					cancel()
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(queue)
+		for i := range items {
+			select {
+			case queue <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-ticker.C:
+			progress(t.progress(m.IsPaused()))
+		}
+	}
+	progress(t.progress(m.IsPaused()))
+
+	select {
+	case err = <-errs:
+	default:
+		err = ctx.Err()
+	}
+	return
+}
+
+// download keeps downloading the item until it is done, waiting out any pause
+func (m *DownloadManager) download(ctx context.Context, dir string, item *DownloadItem, ip *itemTracker) (err error) {
+	if err = os.MkdirAll(dir, 0777); err != nil {
+		return
+	}
+	for {
+		pause, resume := m.channels()
+		select {
+		case <-resume:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		tCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-pause:
+				cancel()
+			case <-tCtx.Done():
+			}
+		}()
+		ip.start()
+		item.File, item.Source, err = DownloadFromMirrors(tCtx, item.Sources, dir, item.Verify, ip.update)
+		cancel()
+		if err == nil {
+			ip.finish()
+			return
+		}
+		if ctx.Err() != nil || !isClosed(pause) {
+			return
+		}
+	}
+}
+
+func (m *DownloadManager) channels() (pause chan struct{}, resume chan struct{}) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.pause, m.resume
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+// tracker holds the progress of every item, each written to by its worker and read when progress is reported
+type tracker struct {
+	mutex sync.Mutex
+	items []*itemTracker
+}
+
+type itemTracker struct {
+	t *tracker
+	ItemProgress
+	// started and startedAt are when and at how many bytes the current transfer started, to measure its speed
+	started   time.Time
+	startedAt int64
+}
+
+func newTracker(items []*DownloadItem) *tracker {
+	t := &tracker{items: make([]*itemTracker, len(items))}
+	for i, item := range items {
+		t.items[i] = &itemTracker{t: t, ItemProgress: ItemProgress{Name: item.Name, Total: -1}}
+	}
+	return t
+}
+
+func (t *tracker) item(i int) *itemTracker {
+	return t.items[i]
+}
+
+func (t *tracker) progress(paused bool) (p DownloadProgress) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	p.Items = make([]ItemProgress, len(t.items))
+	p.Paused = paused
+	for i, it := range t.items {
+		p.Items[i] = it.ItemProgress
+		p.Written += it.Written
+		if it.Total < 0 || p.Total < 0 {
+			p.Total = -1
+		} else {
+			p.Total += it.Total
+		}
+		if !it.Done && !paused {
+			p.Speed += it.Speed
+		}
+	}
+	p.ETA = -1
+	if p.Total >= 0 && p.Speed > 0 {
+		p.ETA = time.Duration(float64(p.Total-p.Written) / p.Speed * float64(time.Second))
+	}
+	return
+}
+
+// start resets the speed measurement for a new transfer
+func (it *itemTracker) start() {
+	it.t.mutex.Lock()
+	defer it.t.mutex.Unlock()
+	it.started = time.Time{}
+	it.Speed = 0
+}
+
+func (it *itemTracker) update(written, total int64) {
+	it.t.mutex.Lock()
+	defer it.t.mutex.Unlock()
+	now := time.Now()
+	if it.started.IsZero() || written < it.startedAt {
+		it.started, it.startedAt = now, written
+	}
+	if elapsed := now.Sub(it.started).Seconds(); elapsed > 0 {
+		it.Speed = float64(written-it.startedAt) / elapsed
+	}
+	it.Written, it.Total = written, total
+}
+
+func (it *itemTracker) finish() {
+	it.t.mutex.Lock()
+	defer it.t.mutex.Unlock()
+	if it.Total < 0 {
+		it.Total = it.Written
+	}
+	it.Written = it.Total
+	it.Done = true
+	it.Speed = 0
+}
+
+func (p ItemProgress) String() string {
+	if p.Done {
+		return fmt.Sprintf("%s: done, %s", p.Name, byteCount(p.Written))
+	}
+	s := fmt.Sprintf("%s: %s", p.Name, byteCount(p.Written))
+	if p.Total >= 0 {
+		s += " of " + byteCount(p.Total)
+	}
+	if p.Speed > 0 {
+		s += fmt.Sprintf(" at %s/s", byteCount(int64(p.Speed)))
+	}
+	return s
+}
+
+// Fraction is how much of the download is done from 0 to 1, or -1 when that is not known
+func (p DownloadProgress) Fraction() float64 {
+	if p.Total <= 0 {
+		return -1
+	}
+	return float64(p.Written) / float64(p.Total)
+}
+
+func (p DownloadProgress) String() string {
+	done := 0
+	for _, i := range p.Items {
+		if i.Done {
+			done++
+		}
+	}
+	s := fmt.Sprintf("Downloading %d of %d: %s", done, len(p.Items), byteCount(p.Written))
+	if p.Total >= 0 {
+		s += " of " + byteCount(p.Total)
+	}
+	if p.Paused {
+		return s + ", paused"
+	}
+	if p.Speed > 0 {
+		s += fmt.Sprintf(" at %s/s", byteCount(int64(p.Speed)))
+	}
+	if p.ETA >= 0 {
+		s += fmt.Sprintf(", %v left", p.ETA.Round(time.Second))
+	}
+	return s
+}
+
+func byteCount(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,123 @@
+package browser
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	resolversMutex sync.Mutex
+	resolvers      = make(map[string]SchemeResolver)
+
+	driveFileRegex   = regexp.MustCompile(`^/file/d/([^/]+)`)
+	unsupportedHosts = map[string]string{
+		"mega.nz":    "MEGA",
+		"mega.co.nz": "MEGA",
+	}
+)
+
+// SchemeResolver returns where the url of a scheme http cannot fetch, such as nxm, is downloaded from
+type SchemeResolver func(ctx context.Context, url string) (string, error)
+
+// RegisterResolver makes downloads of the scheme's urls go through the resolver
+func RegisterResolver(scheme string, resolver SchemeResolver) {
+	resolversMutex.Lock()
+	defer resolversMutex.Unlock()
+	resolvers[strings.ToLower(scheme)] = resolver
+}
+
+// resolve turns the url into one that downloads the file itself
+func resolve(ctx context.Context, rawURL string) (string, error) {
+	if i := strings.Index(rawURL, "://"); i > 0 {
+		resolversMutex.Lock()
+		r, ok := resolvers[strings.ToLower(rawURL[:i])]
+		resolversMutex.Unlock()
+		if ok {
+			u, err := r(ctx, rawURL)
+			if err != nil {
+				return "", err
+			}
+			rawURL = u
+		}
+	}
+	return ResolveURL(rawURL)
+}
+
+// ResolveURL rewrites links to the pages of known hosts into links that download the file itself
+func ResolveURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid url %s: %v", rawURL, err)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if name, ok := unsupportedHosts[host]; ok {
+		return "", fmt.Errorf("%s links cannot be downloaded directly, the mod's author needs to provide a different source for %s", name, rawURL)
+	}
+	switch host {
+	case "github.com":
+		return resolveGitHub(u), nil
+	case "drive.google.com":
+		return resolveGoogleDrive(u), nil
+	case "dropbox.com":
+		q := u.Query()
+		q.Set("dl", "1")
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
+
+// resolveGitHub turns /owner/repo/blob/ref/path and /owner/repo/raw/ref/path into raw.githubusercontent.com links.
+// Release assets at /owner/repo/releases/download/tag/file are already direct.
+func resolveGitHub(u *url.URL) string {
+	sp := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 4)
+	if len(sp) == 4 && (sp[2] == "blob" || sp[2] == "raw") {
+		return (&url.URL{
+			Scheme: "https",
+			Host:   "raw.githubusercontent.com",
+			Path:   "/" + sp[0] + "/" + sp[1] + "/" + sp[3],
+		}).String()
+	}
+	return u.String()
+}
+
+// resolveGoogleDrive turns share links, /file/d/<id>/view and /open?id=<id>, into download links
+func resolveGoogleDrive(u *url.URL) string {
+	id := u.Query().Get("id")
+	if m := driveFileRegex.FindStringSubmatch(u.Path); m != nil {
+		id = m[1]
+	}
+	if id == "" {
+		return u.String()
+	}
+	return "https://drive.usercontent.google.com/download?export=download&confirm=t&id=" + url.QueryEscape(id)
+}
+
+// isGitHubAsset is whether the url is a release asset of the GitHub API, which only sends the file when asked for it
+func isGitHubAsset(u string) bool {
+	return strings.HasPrefix(u, "https://api.github.com/repos/") && strings.Contains(u, "/releases/assets/")
+}
+
+// checkNotPage returns an error when the response is a web page instead of a file, judged by its Content-Type and
+// its first bytes. The returned reader replaces the body as the first bytes are consumed.
+func checkNotPage(u string, resp *http.Response) (*bufio.Reader, error) {
+	r := bufio.NewReader(resp.Body)
+	if mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && mt == "text/html" {
+		return nil, notAFile(u)
+	}
+	b, _ := r.Peek(512)
+	if strings.HasPrefix(http.DetectContentType(b), "text/html") {
+		return nil, notAFile(u)
+	}
+	return r, nil
+}
+
+func notAFile(u string) error {
+	return fmt.Errorf("%s is a web page rather than a file, it needs to be a direct download link", u)
+}
@@ -1,6 +1,7 @@
 package browser
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -29,9 +30,13 @@ func CheckForUpdate() (hasNewer bool, version string, err error) {
 		tags []tag
 		vn   = versionToInt(Version)
 	)
-	if r, err = http.Get(tagUrl); err != nil {
+	if r, err = get(context.Background(), tagUrl); err != nil {
 		return
 	}
+	defer func() { _ = r.Body.Close() }()
+	if r.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("failed to check for updates: %s", r.Status)
+	}
 	if b, err = io.ReadAll(r.Body); err != nil {
 		return
 	}
@@ -0,0 +1,43 @@
+package browser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// VerifyFile checks the file's size and SHA-256 against the expected ones, an empty sha or a size of 0 is not checked
+func VerifyFile(file string, sha string, size int64) error {
+	if sha == "" && size == 0 {
+		return nil
+	}
+	actual, actualSize, err := Checksum(file)
+	if err != nil {
+		return err
+	}
+	if size != 0 && actualSize != size {
+		return fmt.Errorf("expected %d bytes but downloaded %d", size, actualSize)
+	}
+	if sha != "" && !strings.EqualFold(actual, sha) {
+		return fmt.Errorf("expected SHA-256 %s but downloaded %s", sha, actual)
+	}
+	return nil
+}
+
+// Checksum is the file's SHA-256 as hex and its size
+func Checksum(file string) (sha string, size int64, err error) {
+	var f *os.File
+	if f, err = os.Open(file); err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if size, err = io.Copy(h, f); err != nil {
+		return
+	}
+	sha = hex.EncodeToString(h.Sum(nil))
+	return
+}
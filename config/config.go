@@ -2,8 +2,12 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/persist"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 )
 
 const file = "modsync.config"
@@ -20,16 +24,22 @@ var (
 )
 
 type ConfigData struct {
-	WindowX   int    `json:"width"`
-	WindowY   int    `json:"height"`
-	DirI      string `json:"dir1"`
-	DirII     string `json:"dir2"`
-	DirIII    string `json:"dir3"`
-	DirIV     string `json:"dir4"`
-	DirV      string `json:"dir5"`
-	DirVI     string `json:"dir6"`
-	ModDir    string `json:"mod-dir"`
-	BackupDir string `json:"backup-dir"`
+	WindowX int `json:"width"`
+	WindowY int `json:"height"`
+	// GameDirs are the dirs of the games keyed by their ids
+	GameDirs  map[string]string `json:"game-dirs,omitempty"`
+	ModDir    string            `json:"mod-dir"`
+	BackupDir string            `json:"backup-dir"`
+	// CacheSize is how many MB of downloads are kept, 0 uses the default
+	CacheSize int `json:"cache-size-mb"`
+	// Proxy is the url of the proxy downloads go through, when empty the environment's proxy is used
+	Proxy string `json:"proxy"`
+	// Offline stops every feature that needs the network
+	Offline bool `json:"offline"`
+	// NexusAPIKey is the user's personal key for the Nexus Mods API
+	NexusAPIKey string `json:"nexus-api-key"`
+	// HandleNexusLinks makes the manager the handler of nxm links, which other mod managers may be
+	HandleNexusLinks bool `json:"handle-nexus-links"`
 }
 
 func Get() *ConfigData {
@@ -37,103 +47,77 @@ func Get() *ConfigData {
 }
 
 func init() {
-	var (
-		b   []byte
-		err error
-	)
+	var err error
 	if PWD, err = os.Getwd(); err != nil {
 		PWD = "."
 	}
-	if b, err = os.ReadFile(path.Join(PWD, file)); err == nil {
-		_ = json.Unmarshal(b, &config)
-	}
+	_ = persist.LoadJSON(path.Join(PWD, file), &config)
+	migrateGameDirs()
 }
 
-func Save() {
-	if f, e1 := os.Create(path.Join(PWD, file)); e1 == nil {
-		if config.WindowX == 0 {
-			config.WindowX = WindowWidth
-		}
-		if config.WindowY == 0 {
-			config.WindowY = WindowHeight
-		}
-		b, err := json.Marshal(&config)
-		if err == nil {
-			os.WriteFile(path.Join(PWD, file), b, 644)
+// migrateGameDirs moves the games' dirs from the keys they were configured with before GameDirs
+func migrateGameDirs() {
+	var legacy map[string]interface{}
+	if b, err := ioutil.ReadFile(path.Join(PWD, file)); err != nil || json.Unmarshal(b, &legacy) != nil {
+		return
+	}
+	for _, d := range gameDefs {
+		if dir, ok := legacy[d.LegacyDirKey].(string); ok && dir != "" && d.LegacyDirKey != "" {
+			if config.GameDirs == nil {
+				config.GameDirs = make(map[string]string)
+			}
+			if _, found := config.GameDirs[d.ID]; !found {
+				config.GameDirs[d.ID] = dir
+			}
 		}
-		_, _ = f.Write(b)
 	}
 }
 
+func Save() error {
+	if config.WindowX == 0 {
+		config.WindowX = WindowWidth
+	}
+	if config.WindowY == 0 {
+		config.WindowY = WindowHeight
+	}
+	b, err := json.Marshal(&config)
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s: %v", file, err)
+	}
+	return persist.Save(path.Join(PWD, file), b)
+}
+
 func (c *ConfigData) SetGameDir(dir string, game Game) {
-	switch game {
-	case I:
-		Get().DirI = dir
-	case II:
-		Get().DirII = dir
-	case III:
-		Get().DirIII = dir
-	case IV:
-		Get().DirIV = dir
-	case V:
-		Get().DirV = dir
-	case VI:
-		Get().DirVI = dir
+	if c.GameDirs == nil {
+		c.GameDirs = make(map[string]string)
 	}
+	c.GameDirs[String(game)] = dir
 }
 
+// GetModDir is the game's configured dir. When it is not configured, or is relative, it is under the manager's own
+// mods dir.
 func GetModDir(game Game) (dir string) {
-	switch game {
-	case I:
-		dir = Get().DirI
-		if dir == "" {
-			dir = "I"
-		}
-	case II:
-		dir = Get().DirII
-		if dir == "" {
-			dir = "II"
-		}
-	case III:
-		dir = Get().DirIII
-		if dir == "" {
-			dir = "III"
-		}
-	case IV:
-		dir = Get().DirIV
-		if dir == "" {
-			dir = "IV"
-		}
-	case V:
-		dir = Get().DirV
-		if dir == "" {
-			dir = "V"
-		}
-	case VI:
-		dir = Get().DirVI
-		if dir == "" {
-			dir = "VI"
-		}
+	if dir = Get().GameDirs[String(game)]; dir == "" {
+		dir = String(game)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = path.Join(PWD, "mods", dir)
 	}
-	dir = path.Join(PWD, "mods", dir)
 	return
 }
 
-func GetBackupDir(game Game) (s string) {
-	switch game {
-	case I:
-		s = "I"
-	case II:
-		s = "II"
-	case III:
-		s = "III"
-	case IV:
-		s = "IV"
-	case V:
-		s = "V"
-	case VI:
-		s = "VI"
+// GetModStorageDir is where the game's mods are kept, with their definitions and downloads. It is never the game's dir
+// so nothing of the manager's ends up in the game: it is under the configured ModDir, or the manager's own mods dir.
+func GetModStorageDir(game Game) (dir string) {
+	if dir = Get().ModDir; dir == "" {
+		dir = path.Join(PWD, "mods")
+	} else if !filepath.IsAbs(dir) {
+		dir = path.Join(PWD, dir)
 	}
-	s = path.Join(PWD, "backup", s)
+	return path.Join(dir, String(game))
+}
+
+func GetBackupDir(game Game) (s string) {
+	s = path.Join(PWD, "backup", String(game))
 	return
 }
@@ -1,95 +1,118 @@
 package config
 
-type GameName string
-
-const (
-	FfPrI   GameName = "FF PR I"
-	FfPrII  GameName = "FF PR II"
-	FfPrIII GameName = "FF PR III"
-	FfPrIV  GameName = "FF PR IV"
-	FfPrV   GameName = "FF PR V"
-	FfPrVI  GameName = "FF PR VI"
+import (
+	_ "embed"
+	"encoding/json"
+	"strings"
 )
 
+// GameName is the token mods name the games they support with, e.g. "FF PR VI"
+type GameName string
+
+// Game is a game's position in the definitions file. Definitions are only ever added to the end of the file so a
+// game's number, which tracked mods are saved with, does not change.
 type Game int
 
-const (
-	I Game = iota
-	II
-	III
-	IV
-	V
-	VI
+// GameDef is a game the mod manager supports, as defined in games.json
+type GameDef struct {
+	// ID is the game's short name, e.g. "VI", which it is known by in links and its mod and backup dirs
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	GameName GameName `json:"gameName"`
+	// SteamAppID is the game's id on Steam, 0 when it is not sold there
+	SteamAppID int `json:"steamAppId,omitempty"`
+	// NexusDomain is the game's name in Nexus Mods links, empty when Nexus Mods does not host its mods
+	NexusDomain string `json:"nexusDomain,omitempty"`
+	// InstallPaths are where the game is usually installed, a leading ~ is the user's home dir
+	InstallPaths []string `json:"installPaths,omitempty"`
+	// InstallTypes are the mods.InstallType values of the downloads the game's mods may use
+	InstallTypes []string `json:"installTypes"`
+	// LegacyDirKey is the key the game's dir was configured with before the games were defined in games.json
+	LegacyDirKey string `json:"legacyDirKey,omitempty"`
+}
+
+var (
+	//go:embed games.json
+	gamesJSON []byte
+
+	gameDefs = loadGameDefs()
 )
 
-func String(game Game) (name string) {
-	switch game {
-	case I:
-		name = "I"
-	case II:
-		name = "II"
-	case III:
-		name = "III"
-	case IV:
-		name = "IV"
-	case V:
-		name = "V"
-	case VI:
-		name = "VI"
+func loadGameDefs() (defs []*GameDef) {
+	if err := json.Unmarshal(gamesJSON, &defs); err != nil {
+		panic("games.json is not valid: " + err.Error())
 	}
 	return
 }
 
+// Games are all the supported games, in the order they are defined
+func Games() []Game {
+	games := make([]Game, len(gameDefs))
+	for i := range games {
+		games[i] = Game(i)
+	}
+	return games
+}
+
+func IsGame(game Game) bool {
+	return game >= 0 && int(game) < len(gameDefs)
+}
+
+// GetGameDef is the game's definition, or an empty one when the game is not defined
+func GetGameDef(game Game) *GameDef {
+	if !IsGame(game) {
+		return &GameDef{}
+	}
+	return gameDefs[game]
+}
+
+func String(game Game) string {
+	return GetGameDef(game).ID
+}
+
 func GameNameString(game Game) string {
-	return "Final Fantasy " + String(game)
+	return GetGameDef(game).Name
+}
+
+func GameToName(game Game) GameName {
+	return GetGameDef(game).GameName
 }
 
-func FromString(s string) (game Game) {
-	switch s {
-	case "Final Fantasy I":
-		return I
-	case "Final Fantasy II":
-		return II
-	case "Final Fantasy III":
-		return III
-	case "Final Fantasy IV":
-		return IV
-	case "Final Fantasy V":
-		return V
-	case "Final Fantasy VI":
-		return VI
+// FromString finds the game by its id or display name, ignoring case
+func FromString(s string) (Game, bool) {
+	for i, d := range gameDefs {
+		if strings.EqualFold(s, d.ID) || strings.EqualFold(s, d.Name) {
+			return Game(i), true
+		}
 	}
-	return VI
+	return 0, false
 }
 
-func NameToGame(n GameName) Game {
-	switch n {
-	case FfPrI:
-		return I
-	case FfPrII:
-		return II
-	case FfPrIII:
-		return III
-	case FfPrIV:
-		return IV
-	case FfPrV:
-		return V
+// FromNexusDomain finds the game by its name in Nexus Mods links, ignoring case
+func FromNexusDomain(domain string) (Game, bool) {
+	for i, d := range gameDefs {
+		if d.NexusDomain != "" && strings.EqualFold(domain, d.NexusDomain) {
+			return Game(i), true
+		}
 	}
-	return VI
+	return 0, false
 }
 
-func GameToName(game Game) GameName {
-	switch game {
-	case I:
-		return FfPrI
-	case II:
-		return FfPrII
-	case III:
-		return FfPrIII
-	case IV:
-		return FfPrIV
-	case V:
-		return FfPrV
+func NameToGame(n GameName) (Game, bool) {
+	for i, d := range gameDefs {
+		if d.GameName == n {
+			return Game(i), true
+		}
+	}
+	return 0, false
+}
+
+// SupportsInstallType is whether the game's mods may use downloads of the install type
+func SupportsInstallType(game Game, installType string) bool {
+	for _, t := range GetGameDef(game).InstallTypes {
+		if t == installType {
+			return true
+		}
 	}
-	return FfPrVI
+	return false
 }
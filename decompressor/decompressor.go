@@ -1,21 +1,257 @@
 package decompressor
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
+	"github.com/kiamev/moogle-mod-manager/mods"
+	"github.com/ulikunitz/xz"
+	"io"
+	"os"
 	"path"
+	"strings"
 )
 
 type Decompressor interface {
 	DecompressTo(dest string) error
+	// Decompress extracts the archive into dest, reporting to progress, which may be nil, as it goes. If ctx is
+	// cancelled or the extraction fails, everything extracted so far is removed.
+	Decompress(ctx context.Context, dest string, progress ProgressFunc) error
+	// DecompressFiles extracts only the entries the files and dirs copy from. When every entry is in one folder and the
+	// From paths are relative to that folder, it is left out of the extracted paths. If any of the From paths is not in
+	// the archive, an error listing them is returned before anything is extracted.
+	DecompressFiles(ctx context.Context, dest string, files *mods.DownloadFiles, progress ProgressFunc) error
+	List() ([]Entry, error)
 }
 
+type Entry struct {
+	Name  string
+	Size  int64
+	IsDir bool
+}
+
+// Progress of an extraction. The totals are 0 when the archive's format does not list its entries up front.
+type Progress struct {
+	Entries      int
+	TotalEntries int
+	Written      int64
+	TotalBytes   int64
+}
+
+type ProgressFunc func(p Progress)
+
+// archive is implemented by each supported format and streams its entries to the extractor
+type archive interface {
+	extractTo(x *extractor) error
+}
+
+type decompressor struct {
+	archive
+}
+
+func (d decompressor) DecompressTo(dest string) error {
+	return d.Decompress(context.Background(), dest, nil)
+}
+
+func (d decompressor) Decompress(ctx context.Context, dest string, progress ProgressFunc) error {
+	return d.decompress(ctx, dest, progress, nil, "")
+}
+
+func (d decompressor) DecompressFiles(ctx context.Context, dest string, files *mods.DownloadFiles, progress ProgressFunc) error {
+	entries, err := d.list(ctx)
+	if err != nil {
+		return err
+	}
+	m := newMappings(files)
+	root, missing := m.match(entries)
+	if len(missing) > 0 {
+		return fmt.Errorf("%s does not contain %s", files.DownloadName, strings.Join(missing, ", "))
+	}
+	return d.decompress(ctx, dest, progress, m.includes, root)
+}
+
+func (d decompressor) List() ([]Entry, error) {
+	entries, err := d.list(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	l := make([]Entry, len(entries))
+	for i, e := range entries {
+		l[i] = Entry{Name: e.name, Size: e.size, IsDir: e.isDir}
+	}
+	return l, nil
+}
+
+func (d decompressor) list(ctx context.Context) ([]entry, error) {
+	x := &extractor{ctx: ctx, listing: true}
+	if err := d.extractTo(x); err != nil {
+		return nil, err
+	}
+	return x.listed, nil
+}
+
+func (d decompressor) decompress(ctx context.Context, dest string, progress ProgressFunc, include func(string) bool, root string) (err error) {
+	var x *extractor
+	if x, err = newExtractor(ctx, dest, progress); err != nil {
+		return
+	}
+	x.include = include
+	x.root = root
+	if err = d.extractTo(x); err != nil {
+		x.removeExtracted()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}
+	return
+}
+
+type format byte
+
+const (
+	unknownFormat format = iota
+	zipFormat
+	sevenZipFormat
+	rarFormat
+	tarFormat
+	gzipFormat
+	xzFormat
+)
+
+var (
+	zipMagic      = []byte("PK\x03\x04")
+	emptyZipMagic = []byte("PK\x05\x06")
+	sevenZipMagic = []byte("7z\xBC\xAF\x27\x1C")
+	rarMagic      = []byte("Rar!\x1A\x07")
+	gzipMagic     = []byte("\x1F\x8B")
+	xzMagic       = []byte("\xFD7zXZ\x00")
+	tarMagic      = []byte("ustar")
+)
+
+const (
+	tarMagicOffset = 257
+	headerSize     = 512
+)
+
+// NewDecompressor detects the archive's format from its content so that mis-named archives still work, falling back
+// to the file's extension
 func NewDecompressor(src string) (Decompressor, error) {
-	switch path.Ext(src) {
-	case ".7z":
-		return new7zDecompressor(src), nil
-	case ".zip", ".rar":
+	f, err := detectFormat(src)
+	if err != nil {
+		return nil, err
+	}
+	if f == unknownFormat {
+		f = formatFromExt(src)
+	}
+	switch f {
+	case zipFormat:
 		return newArchiveDecompressor(src), nil
+	case sevenZipFormat:
+		return new7zDecompressor(src), nil
+	case rarFormat:
+		return newRarDecompressor(src), nil
+	case tarFormat:
+		return newTarDecompressor(src, noCompression), nil
+	case gzipFormat, xzFormat:
+		var c compression = gzipCompression
+		if f == xzFormat {
+			c = xzCompression
+		}
+		if isTar, err := isCompressedTar(src, c); err != nil {
+			return nil, err
+		} else if isTar {
+			return newTarDecompressor(src, c), nil
+		}
+		return newSingleFileDecompressor(src, c), nil
 	default:
-		return nil, fmt.Errorf("unsupported file extension: %s", path.Ext(src))
+		return nil, fmt.Errorf("unsupported file type: %s", path.Base(src))
+	}
+}
+
+func detectFormat(src string) (format, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return unknownFormat, err
+	}
+	defer func() { _ = f.Close() }()
+
+	b := make([]byte, headerSize)
+	n, err := io.ReadFull(f, b)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return unknownFormat, err
+	}
+	b = b[:n]
+	switch {
+	case bytes.HasPrefix(b, zipMagic), bytes.HasPrefix(b, emptyZipMagic):
+		return zipFormat, nil
+	case bytes.HasPrefix(b, sevenZipMagic):
+		return sevenZipFormat, nil
+	case bytes.HasPrefix(b, rarMagic):
+		return rarFormat, nil
+	case bytes.HasPrefix(b, gzipMagic):
+		return gzipFormat, nil
+	case bytes.HasPrefix(b, xzMagic):
+		return xzFormat, nil
+	case hasTarMagic(b):
+		return tarFormat, nil
+	}
+	return unknownFormat, nil
+}
+
+func formatFromExt(src string) format {
+	s := strings.ToLower(src)
+	switch {
+	case strings.HasSuffix(s, ".zip"):
+		return zipFormat
+	case strings.HasSuffix(s, ".7z"):
+		return sevenZipFormat
+	case strings.HasSuffix(s, ".rar"):
+		return rarFormat
+	case strings.HasSuffix(s, ".tar"):
+		return tarFormat
+	case strings.HasSuffix(s, ".gz"), strings.HasSuffix(s, ".tgz"):
+		return gzipFormat
+	case strings.HasSuffix(s, ".xz"), strings.HasSuffix(s, ".txz"):
+		return xzFormat
+	}
+	return unknownFormat
+}
+
+func hasTarMagic(b []byte) bool {
+	return len(b) >= tarMagicOffset+len(tarMagic) && bytes.Equal(b[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic)
+}
+
+func isCompressedTar(src string, c compression) (bool, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+
+	r, err := c.reader(f)
+	if err != nil {
+		return false, err
+	}
+	b := make([]byte, headerSize)
+	n, _ := io.ReadFull(r, b)
+	return hasTarMagic(b[:n]), nil
+}
+
+type compression byte
+
+const (
+	noCompression compression = iota
+	gzipCompression
+	xzCompression
+)
+
+func (c compression) reader(r io.Reader) (io.Reader, error) {
+	switch c {
+	case gzipCompression:
+		return gzip.NewReader(r)
+	case xzCompression:
+		return xz.NewReader(r)
 	}
+	return r, nil
 }
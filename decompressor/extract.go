@@ -0,0 +1,322 @@
+package decompressor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// entry is a file, directory or link in an archive
+type entry struct {
+	name  string
+	mode  os.FileMode
+	isDir bool
+	size  int64
+	// link is the target of a symbolic link, relative to the link's directory
+	link string
+	// hardLink is the target of a hard link, relative to the archive's root
+	hardLink string
+}
+
+// extractor writes archive entries under dest. Every entry, including the targets of links, must resolve inside dest.
+// Absolute names, ".." components that climb out of dest and links that point outside of it are refused.
+type extractor struct {
+	ctx      context.Context
+	dest     string
+	progress ProgressFunc
+	p        Progress
+	// created is every file and top-most directory the extraction created, so they can be removed if it does not finish
+	created []string
+	// include limits the extraction to the entries it returns true for, nil includes every entry
+	include func(name string) bool
+	// root, when set, is the folder every entry is in, which is left out of the extracted paths
+	root string
+	// listing records the archive's entries instead of extracting them
+	listing bool
+	listed  []entry
+}
+
+// progressInterval is how many bytes are written between progress reports
+const progressInterval = 1 << 20
+
+func newExtractor(ctx context.Context, dest string, progress ProgressFunc) (x *extractor, err error) {
+	x = &extractor{ctx: ctx, progress: progress}
+	if dest, err = filepath.Abs(dest); err != nil {
+		return
+	}
+	if err = x.mkdirAll(dest, 0777); err != nil {
+		return
+	}
+	if x.dest, err = filepath.EvalSymlinks(dest); err != nil {
+		return
+	}
+	return
+}
+
+func (x *extractor) setTotals(entries int, bytes int64) {
+	x.p.TotalEntries = entries
+	x.p.TotalBytes = bytes
+	x.report()
+}
+
+func (x *extractor) report() {
+	if x.progress != nil {
+		x.progress(x.p)
+	}
+}
+
+// removeExtracted removes everything the extraction created, newest first
+func (x *extractor) removeExtracted() {
+	for i := len(x.created) - 1; i >= 0; i-- {
+		_ = os.RemoveAll(x.created[i])
+	}
+	x.created = nil
+}
+
+func (x *extractor) includes(name string) bool {
+	var ok bool
+	if name, ok = x.stripRoot(name); !ok {
+		return false
+	}
+	return x.include == nil || x.include(name)
+}
+
+// stripRoot is the name without the root folder, false for the root folder itself
+func (x *extractor) stripRoot(name string) (string, bool) {
+	if x.root == "" {
+		return name, true
+	}
+	return stripRoot(name, x.root)
+}
+
+// extract writes the entry, opening its content only if it is extracted
+func (x *extractor) extract(e entry, open func() (io.ReadCloser, error)) (err error) {
+	if err = x.ctx.Err(); err != nil {
+		return
+	}
+	if x.listing {
+		x.listed = append(x.listed, e)
+		return
+	}
+	if !x.includes(e.name) {
+		return
+	}
+	e.name, _ = x.stripRoot(e.name)
+	if e.hardLink != "" {
+		e.hardLink, _ = x.stripRoot(e.hardLink)
+	}
+	var rc io.ReadCloser
+	if rc, err = open(); err != nil {
+		return
+	}
+	defer func() { _ = rc.Close() }()
+	if err = x.extractEntry(e, rc); err != nil {
+		return
+	}
+	x.p.Entries++
+	x.report()
+	return
+}
+
+func (x *extractor) extractEntry(e entry, r io.Reader) (err error) {
+	var (
+		fp   string
+		file *os.File
+		mode = e.mode
+	)
+	if fp, err = x.resolve(e.name); err != nil {
+		return
+	}
+	if fp == x.dest {
+		if e.isDir {
+			return nil
+		}
+		return unsafeEntry(e.name, "it is not a file")
+	}
+	if err = x.checkParents(e.name, fp); err != nil {
+		return
+	}
+
+	if mode.Perm() == 0 {
+		mode |= 0777
+	}
+	switch {
+	case e.isDir:
+		return x.mkdirAll(fp, mode.Perm()|0700)
+	case e.link != "" || mode&os.ModeSymlink != 0:
+		return x.symlink(e, fp, r)
+	case e.hardLink != "":
+		return x.hardLink(e, fp)
+	}
+
+	if err = x.mkdirAll(filepath.Dir(fp), 0777); err != nil {
+		return
+	}
+	// Replace, rather than write through, a link an earlier entry created
+	if fi, lErr := os.Lstat(fp); lErr == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err = os.Remove(fp); err != nil {
+			return
+		}
+	}
+	if file, err = x.create(fp, mode.Perm()|0600); err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+	_, err = io.Copy(file, &progressReader{x: x, r: r})
+	return
+}
+
+// readerOpener is the opener of an entry of a streamed archive, whose content is read from the archive's reader
+func readerOpener(r io.Reader) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(r), nil
+	}
+}
+
+func (x *extractor) create(fp string, mode os.FileMode) (*os.File, error) {
+	if _, err := os.Lstat(fp); os.IsNotExist(err) {
+		x.created = append(x.created, fp)
+	}
+	return os.OpenFile(fp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+}
+
+func (x *extractor) mkdirAll(dir string, mode os.FileMode) error {
+	first := ""
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Lstat(d); err == nil || filepath.Dir(d) == d {
+			break
+		}
+		first = d
+	}
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	if first != "" {
+		x.created = append(x.created, first)
+	}
+	return nil
+}
+
+// progressReader reports the bytes written and stops the copy once the extraction is cancelled
+type progressReader struct {
+	x      *extractor
+	r      io.Reader
+	unsent int64
+}
+
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	if err = r.x.ctx.Err(); err != nil {
+		return
+	}
+	n, err = r.r.Read(p)
+	r.x.p.Written += int64(n)
+	if r.unsent += int64(n); r.unsent >= progressInterval {
+		r.unsent = 0
+		r.x.report()
+	}
+	return
+}
+
+func (x *extractor) symlink(e entry, fp string, r io.Reader) (err error) {
+	target := e.link
+	if target == "" {
+		// zip, 7z and rar store the link's target as the entry's content
+		var b []byte
+		if b, err = ioutil.ReadAll(io.LimitReader(r, 4096)); err != nil {
+			return
+		}
+		target = string(b)
+	}
+	target = toSlash(target)
+	if isAbs(target) {
+		return unsafeEntry(e.name, "it links to the absolute path "+target)
+	}
+	if err = x.mkdirAll(filepath.Dir(fp), 0777); err != nil {
+		return
+	}
+	// Resolve the target from where the link's directory really is, as it may be reached through other links
+	var dir, resolved string
+	if dir, err = filepath.EvalSymlinks(filepath.Dir(fp)); err != nil {
+		return
+	}
+	resolved = filepath.Join(dir, filepath.FromSlash(target))
+	if p, evalErr := filepath.EvalSymlinks(resolved); evalErr == nil {
+		resolved = p
+	}
+	if !within(x.dest, resolved) {
+		return unsafeEntry(e.name, "it links outside of the destination")
+	}
+	_ = os.Remove(fp)
+	x.created = append(x.created, fp)
+	return os.Symlink(filepath.FromSlash(target), fp)
+}
+
+func (x *extractor) hardLink(e entry, fp string) (err error) {
+	var target string
+	if target, err = x.resolve(e.hardLink); err != nil {
+		return unsafeEntry(e.name, "it links outside of the destination")
+	}
+	if err = x.checkParents(e.hardLink, target); err != nil {
+		return
+	}
+	if err = x.mkdirAll(filepath.Dir(fp), 0777); err != nil {
+		return
+	}
+	_ = os.Remove(fp)
+	x.created = append(x.created, fp)
+	return os.Link(target, fp)
+}
+
+// resolve returns the entry's path under dest
+func (x *extractor) resolve(name string) (string, error) {
+	name = toSlash(name)
+	if isAbs(name) {
+		return "", unsafeEntry(name, "it has an absolute path")
+	}
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", unsafeEntry(name, "it resolves outside of the destination")
+	}
+	return filepath.Join(x.dest, filepath.FromSlash(cleaned)), nil
+}
+
+// checkParents makes sure the entry is not written through a previously extracted link that leaves dest
+func (x *extractor) checkParents(name string, fp string) error {
+	dir := filepath.Dir(fp)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if !within(x.dest, resolved) {
+		return unsafeEntry(name, "it is written through a link outside of the destination")
+	}
+	return nil
+}
+
+func within(root string, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func toSlash(name string) string {
+	return strings.ReplaceAll(name, "\\", "/")
+}
+
+func isAbs(name string) bool {
+	return strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':')
+}
+
+func unsafeEntry(name string, reason string) error {
+	return fmt.Errorf("refusing to extract %s: %s", name, reason)
+}
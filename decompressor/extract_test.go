@@ -0,0 +1,256 @@
+package decompressor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type testEntry struct {
+	name     string
+	body     string
+	symlink  string
+	hardLink string
+}
+
+func writeTar(t *testing.T, entries ...testEntry) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "test.tar")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	w := tar.NewWriter(f)
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		if e.symlink != "" {
+			h.Typeflag, h.Linkname, h.Size = tar.TypeSymlink, e.symlink, 0
+		} else if e.hardLink != "" {
+			h.Typeflag, h.Linkname, h.Size = tar.TypeLink, e.hardLink, 0
+		}
+		if err = w.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(e.body)); err != nil && h.Size > 0 {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func writeZip(t *testing.T, entries ...testEntry) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "test.zip")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	w := zip.NewWriter(f)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Store}
+		body := e.body
+		if e.symlink != "" {
+			// zip stores a link's target as its content
+			h.SetMode(os.ModeSymlink | 0777)
+			body = e.symlink
+		} else {
+			h.SetMode(0644)
+		}
+		fw, err := w.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func decompress(t *testing.T, archive string, dest string) error {
+	t.Helper()
+	d, err := NewDecompressor(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.Decompress(context.Background(), dest, nil)
+}
+
+func skipWithoutSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs extra privileges on windows")
+	}
+}
+
+// assertEmpty fails when anything is left in dir, which a failed extraction removes if it created it
+func assertEmpty(t *testing.T, dir string) {
+	t.Helper()
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range fis {
+		t.Errorf("%s was left in the destination", fi.Name())
+	}
+}
+
+func TestExtractRefusesEntriesOutsideDest(t *testing.T) {
+	for _, name := range []string{"../x", "a/../../x", "/abs", "C:/abs", "..\\x"} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				root = t.TempDir()
+				dest = filepath.Join(root, "dest")
+			)
+			err := decompress(t, writeTar(t, testEntry{name: "ok.txt", body: "ok"}, testEntry{name: name, body: "x"}), dest)
+			if err == nil || !strings.Contains(err.Error(), "refusing") {
+				t.Fatalf("expected the entry to be refused, got %v", err)
+			}
+			if _, err = os.Stat(filepath.Join(root, "x")); !os.IsNotExist(err) {
+				t.Error("the entry was written outside of the destination")
+			}
+			assertEmpty(t, dest)
+		})
+	}
+}
+
+func TestExtractKeepsDotsInNames(t *testing.T) {
+	dest := t.TempDir()
+	if err := decompress(t, writeTar(t, testEntry{name: "img/v1..2.png", body: "png"}), dest); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dest, "img", "v1..2.png")); err != nil || string(b) != "png" {
+		t.Fatalf("expected v1..2.png to be extracted, got %q, %v", b, err)
+	}
+}
+
+func TestExtractRefusesTarSymlinkOutsideDest(t *testing.T) {
+	skipWithoutSymlinks(t)
+	var (
+		root = t.TempDir()
+		dest = filepath.Join(root, "dest")
+	)
+	if err := decompress(t, writeTar(t, testEntry{name: "l", symlink: "../outside"}), dest); err == nil {
+		t.Fatal("expected the link to be refused")
+	}
+	if err := decompress(t, writeTar(t, testEntry{name: "l", symlink: "/etc"}), dest); err == nil {
+		t.Fatal("expected the absolute link to be refused")
+	}
+	assertEmpty(t, dest)
+}
+
+func TestExtractTarSymlinkInsideDest(t *testing.T) {
+	skipWithoutSymlinks(t)
+	dest := t.TempDir()
+	err := decompress(t, writeTar(t,
+		testEntry{name: "sub/a.txt", body: "a"},
+		testEntry{name: "l", symlink: "sub"},
+		testEntry{name: "l/b.txt", body: "b"}), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dest, "sub", "b.txt")); err != nil || string(b) != "b" {
+		t.Fatalf("expected b.txt to be written through the link, got %q, %v", b, err)
+	}
+}
+
+func TestExtractHardLink(t *testing.T) {
+	dest := t.TempDir()
+	err := decompress(t, writeTar(t, testEntry{name: "a.txt", body: "a"}, testEntry{name: "b.txt", hardLink: "a.txt"}), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dest, "b.txt")); err != nil || string(b) != "a" {
+		t.Fatalf("expected b.txt to link to a.txt, got %q, %v", b, err)
+	}
+
+	var (
+		root = t.TempDir()
+		out  = filepath.Join(root, "secret")
+	)
+	dest = filepath.Join(root, "dest")
+	if err = ioutil.WriteFile(out, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = decompress(t, writeTar(t, testEntry{name: "b.txt", hardLink: "../secret"}), dest); err == nil {
+		t.Fatal("expected the hard link outside of the destination to be refused")
+	}
+	assertEmpty(t, dest)
+}
+
+func TestExtractZipSymlink(t *testing.T) {
+	skipWithoutSymlinks(t)
+	var (
+		root = t.TempDir()
+		dest = filepath.Join(root, "dest")
+	)
+	if err := decompress(t, writeZip(t, testEntry{name: "l", symlink: "../../outside"}), dest); err == nil {
+		t.Fatal("expected the link to be refused")
+	}
+	assertEmpty(t, dest)
+
+	dest = t.TempDir()
+	if err := decompress(t, writeZip(t, testEntry{name: "a.txt", body: "a"}, testEntry{name: "l", symlink: "a.txt"}), dest); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "l")); err != nil || target != "a.txt" {
+		t.Fatalf("expected l to link to a.txt, got %q, %v", target, err)
+	}
+}
+
+func TestExtractReplacesExtractedLink(t *testing.T) {
+	skipWithoutSymlinks(t)
+	dest := t.TempDir()
+	err := decompress(t, writeTar(t,
+		testEntry{name: "target.txt", body: "target"},
+		testEntry{name: "l", symlink: "target.txt"},
+		testEntry{name: "l", body: "replaced"}), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dest, "target.txt")); string(b) != "target" {
+		t.Errorf("the file was written through the link, target.txt is %q", b)
+	}
+	if fi, err := os.Lstat(filepath.Join(dest, "l")); err != nil || fi.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("expected l to be replaced by a file, %v", err)
+	}
+}
+
+func TestExtractRefusesWritingThroughLinkOutsideDest(t *testing.T) {
+	skipWithoutSymlinks(t)
+	var (
+		root    = t.TempDir()
+		dest    = filepath.Join(root, "dest")
+		outside = filepath.Join(root, "outside")
+	)
+	if err := os.MkdirAll(dest, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(outside, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dest, "out")); err != nil {
+		t.Fatal(err)
+	}
+	if err := decompress(t, writeTar(t, testEntry{name: "out/f.txt", body: "f"}), dest); err == nil {
+		t.Fatal("expected writing through the link to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "f.txt")); !os.IsNotExist(err) {
+		t.Error("the file was written outside of the destination")
+	}
+}
@@ -0,0 +1,141 @@
+package decompressor
+
+import (
+	"github.com/kiamev/moogle-mod-manager/mods"
+	"path"
+	"strings"
+)
+
+// mappings matches archive entries against the From paths of a download's files and dirs
+type mappings struct {
+	files     []string
+	dirs      []string
+	recursive []bool
+}
+
+func newMappings(dlf *mods.DownloadFiles) *mappings {
+	m := &mappings{}
+	for _, f := range dlf.Files {
+		m.files = append(m.files, normalize(f.From))
+	}
+	for _, d := range dlf.Dirs {
+		m.dirs = append(m.dirs, normalize(d.From))
+		m.recursive = append(m.recursive, d.Recursive)
+	}
+	return m
+}
+
+func (m *mappings) includes(name string) bool {
+	name = normalize(name)
+	for _, f := range m.files {
+		if name == f {
+			return true
+		}
+	}
+	for i, d := range m.dirs {
+		if m.recursive[i] {
+			if d == "." || strings.HasPrefix(name, d+"/") {
+				return true
+			}
+		} else if path.Dir(name) == d {
+			return true
+		}
+	}
+	return false
+}
+
+// missing is every From path that no entry of the archive matches
+func (m *mappings) missing(entries []entry) (missing []string) {
+	found := make(map[string]bool)
+	for _, e := range entries {
+		name := normalize(e.name)
+		if !e.isDir {
+			found[name] = true
+		}
+		for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			found[dir+"/"] = true
+		}
+		if e.isDir {
+			found[name+"/"] = true
+		}
+	}
+	for _, f := range m.files {
+		if !found[f] {
+			missing = append(missing, f)
+		}
+	}
+	for _, d := range m.dirs {
+		if d != "." && !found[d+"/"] {
+			missing = append(missing, d)
+		}
+	}
+	return
+}
+
+// match is the root folder to leave out of the entries' paths, empty when the From paths match the entries as they
+// are, and the From paths that match neither way
+func (m *mappings) match(entries []entry) (root string, missing []string) {
+	if missing = m.missing(entries); len(missing) == 0 {
+		return "", nil
+	}
+	if root = commonRoot(entries); root == "" {
+		return "", missing
+	}
+	stripped := make([]entry, 0, len(entries))
+	for _, e := range entries {
+		if name, ok := stripRoot(e.name, root); ok {
+			e.name = name
+			stripped = append(stripped, e)
+		}
+	}
+	if len(m.missing(stripped)) > 0 {
+		return "", missing
+	}
+	return root, nil
+}
+
+// commonRoot is the one folder every entry is in, empty when there is none. Entries with absolute paths or that climb
+// out with .. have none, so they are still refused when extracted.
+func commonRoot(entries []entry) (root string) {
+	for _, e := range entries {
+		name := path.Clean(toSlash(e.name))
+		if isAbs(name) || name == ".." || strings.HasPrefix(name, "../") || name == "." {
+			return ""
+		}
+		first := strings.SplitN(name, "/", 2)[0]
+		if !e.isDir && first == name {
+			// A file at the top of the archive
+			return ""
+		}
+		if root == "" {
+			root = first
+		} else if root != first {
+			return ""
+		}
+	}
+	return
+}
+
+// stripRoot is the name relative to the root folder, false when it is the root folder itself or not in it
+func stripRoot(name string, root string) (string, bool) {
+	name = normalize(name)
+	if !strings.HasPrefix(name, root+"/") {
+		return name, false
+	}
+	return name[len(root)+1:], true
+}
+
+// MissingFiles is every From path of the files and dirs that is not one of the entries, or of the entries without the
+// one folder they are all in
+func MissingFiles(entries []Entry, files *mods.DownloadFiles) []string {
+	l := make([]entry, len(entries))
+	for i, e := range entries {
+		l[i] = entry{name: e.Name, size: e.Size, isDir: e.IsDir}
+	}
+	_, missing := newMappings(files).match(l)
+	return missing
+}
+
+func normalize(name string) string {
+	return path.Clean(strings.TrimPrefix(toSlash(name), "/"))
+}
@@ -0,0 +1,140 @@
+package decompressor
+
+import (
+	"context"
+	"github.com/kiamev/moogle-mod-manager/mods"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func decompressFiles(t *testing.T, archive string, dest string, files *mods.DownloadFiles) error {
+	t.Helper()
+	d, err := NewDecompressor(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.DecompressFiles(context.Background(), dest, files, nil)
+}
+
+// extracted is every file under dir with its content
+func extracted(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		b, err := ioutil.ReadFile(p)
+		files[filepath.ToSlash(rel)] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+var rootedEntries = []testEntry{
+	{name: "mod/"},
+	{name: "mod/assets/file/a.txt", body: "a"},
+	{name: "mod/assets/dir/b.png", body: "b"},
+	{name: "mod/readme.txt", body: "readme"},
+}
+
+func TestDecompressFilesLeavesOutRootFolder(t *testing.T) {
+	dest := t.TempDir()
+	err := decompressFiles(t, writeZip(t, rootedEntries...), dest, &mods.DownloadFiles{
+		DownloadName: "rooted",
+		Files:        []*mods.ModFile{{From: "./assets/file/a.txt", To: "."}},
+		Dirs:         []*mods.ModDir{{From: "assets/dir", To: "."}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"assets/file/a.txt": "a", "assets/dir/b.png": "b"}
+	if got := extracted(t, dest); !reflect.DeepEqual(got, want) {
+		t.Errorf("extracted %v, want %v", got, want)
+	}
+}
+
+func TestDecompressFilesKeepsRootFolderThatIsMapped(t *testing.T) {
+	dest := t.TempDir()
+	err := decompressFiles(t, writeZip(t, rootedEntries...), dest, &mods.DownloadFiles{
+		DownloadName: "rooted",
+		Files:        []*mods.ModFile{{From: "mod/readme.txt", To: "."}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := extracted(t, dest); !reflect.DeepEqual(got, map[string]string{"mod/readme.txt": "readme"}) {
+		t.Errorf("extracted %v", got)
+	}
+}
+
+func TestDecompressFilesReportsMissingFiles(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		entries []testEntry
+		files   *mods.DownloadFiles
+		missing []string
+	}{
+		{
+			name:    "rooted",
+			entries: rootedEntries,
+			files: &mods.DownloadFiles{
+				Files: []*mods.ModFile{{From: "assets/file/a.txt"}, {From: "missing.txt"}},
+				Dirs:  []*mods.ModDir{{From: "assets/missing"}},
+			},
+			missing: []string{"assets/file/a.txt", "assets/missing", "missing.txt"},
+		},
+		{
+			name: "two top folders",
+			entries: []testEntry{
+				{name: "one/a.txt", body: "a"},
+				{name: "two/b.txt", body: "b"},
+			},
+			files:   &mods.DownloadFiles{Files: []*mods.ModFile{{From: "a.txt"}}},
+			missing: []string{"a.txt"},
+		},
+		{
+			name: "file at the top",
+			entries: []testEntry{
+				{name: "one/a.txt", body: "a"},
+				{name: "b.txt", body: "b"},
+			},
+			files:   &mods.DownloadFiles{Files: []*mods.ModFile{{From: "a.txt"}}},
+			missing: []string{"a.txt"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "dest")
+			tt.files.DownloadName = tt.name
+			err := decompressFiles(t, writeZip(t, tt.entries...), dest, tt.files)
+			if err == nil {
+				t.Fatal("DecompressFiles succeeded")
+			}
+			for _, m := range tt.missing {
+				if !strings.Contains(err.Error(), m) {
+					t.Errorf("%v does not report %s", err, m)
+				}
+			}
+			assertEmpty(t, dest)
+
+			var entries []Entry
+			for _, e := range tt.entries {
+				entries = append(entries, Entry{Name: e.name, IsDir: strings.HasSuffix(e.name, "/")})
+			}
+			missing := MissingFiles(entries, tt.files)
+			sort.Strings(missing)
+			if !reflect.DeepEqual(missing, tt.missing) {
+				t.Errorf("MissingFiles = %v, want %v", missing, tt.missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package decompressor
+
+import (
+	"github.com/nwaples/rardecode"
+	"io"
+)
+
+func newRarDecompressor(src string) Decompressor {
+	return &decompressor{archive: &rarDecompressor{src: src}}
+}
+
+// rarDecompressor extracts RAR v4 and v5 archives
+type rarDecompressor struct {
+	src string
+}
+
+func (d rarDecompressor) extractTo(x *extractor) error {
+	r, err := rardecode.OpenReader(d.src, "")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+	var h *rardecode.FileHeader
+	for {
+		if h, err = r.Next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		e := entry{name: h.Name, mode: h.Mode(), isDir: h.IsDir, size: h.UnPackedSize}
+		if err = x.extract(e, readerOpener(r)); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package decompressor
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+func newSingleFileDecompressor(src string, c compression) Decompressor {
+	return &decompressor{archive: &singleFileDecompressor{src: src, compression: c}}
+}
+
+// singleFileDecompressor extracts a lone .gz or .xz compressed file
+type singleFileDecompressor struct {
+	src         string
+	compression compression
+}
+
+func (d singleFileDecompressor) extractTo(x *extractor) error {
+	f, err := os.Open(d.src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	var r io.Reader
+	if r, err = d.compression.reader(f); err != nil {
+		return err
+	}
+	x.setTotals(1, 0)
+	return x.extract(entry{name: d.name(r), mode: 0666}, readerOpener(r))
+}
+
+// name is the file's original name from the gzip header if it has one, otherwise the archive's name without its
+// extension
+func (d singleFileDecompressor) name(r io.Reader) string {
+	if gr, ok := r.(*gzip.Reader); ok && gr.Name != "" {
+		return path.Base(strings.ReplaceAll(gr.Name, "\\", "/"))
+	}
+	name := path.Base(d.src)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
@@ -0,0 +1,55 @@
+package decompressor
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+)
+
+func newTarDecompressor(src string, c compression) Decompressor {
+	return &decompressor{archive: &tarDecompressor{src: src, compression: c}}
+}
+
+// tarDecompressor extracts .tar, .tar.gz and .tar.xz archives
+type tarDecompressor struct {
+	src         string
+	compression compression
+}
+
+func (d tarDecompressor) extractTo(x *extractor) error {
+	f, err := os.Open(d.src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	var r io.Reader
+	if r, err = d.compression.reader(f); err != nil {
+		return err
+	}
+	var (
+		tr = tar.NewReader(r)
+		h  *tar.Header
+	)
+	for {
+		if h, err = tr.Next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		e := entry{name: h.Name, mode: h.FileInfo().Mode(), size: h.Size}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			e.isDir = true
+		case tar.TypeSymlink:
+			e.link = h.Linkname
+		case tar.TypeLink:
+			e.hardLink = h.Linkname
+		case tar.TypeReg:
+		default:
+			continue
+		}
+		if err = x.extract(e, readerOpener(tr)); err != nil {
+			return err
+		}
+	}
+}
@@ -7,7 +7,10 @@ require (
 	github.com/Xuanwo/go-locale v1.1.0
 	github.com/bodgit/sevenzip v1.2.2
 	github.com/ncruces/zenity v0.8.9
+	github.com/nwaples/rardecode v1.1.3
+	github.com/ulikunitz/xz v0.5.10
 	golang.design/x/clipboard v0.6.2
+	golang.org/x/sys v0.0.0-20220627191245-f75cf1eec38b
 )
 
 require (
@@ -41,14 +44,12 @@ require (
 	github.com/srwiley/rasterx v0.0.0-20200120212402-85cb7272f5e9 // indirect
 	github.com/stretchr/testify v1.7.2 // indirect
 	github.com/tevino/abool v1.2.0 // indirect
-	github.com/ulikunitz/xz v0.5.10 // indirect
 	github.com/yuin/goldmark v1.4.0 // indirect
 	go4.org v0.0.0-20200411211856-f5505b9728dd // indirect
 	golang.org/x/exp v0.0.0-20200224162631-6cc2880d07d6 // indirect
 	golang.org/x/image v0.0.0-20220617043117-41969df76e82 // indirect
 	golang.org/x/mobile v0.0.0-20211207041440-4e6c2922fdee // indirect
 	golang.org/x/net v0.0.0-20210805182204-aaa1db679c0d // indirect
-	golang.org/x/sys v0.0.0-20220627191245-f75cf1eec38b // indirect
 	golang.org/x/text v0.3.7 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	honnef.co/go/js/dom v0.0.0-20210725211120-f030747120f2 // indirect
@@ -0,0 +1,88 @@
+package ipc
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const dialTimeout = 2 * time.Second
+
+// socketPath is the unix socket of the instance running from config.PWD. It is under the temp dir as socket paths
+// are limited to about a hundred characters.
+func socketPath() string {
+	h := sha256.Sum256([]byte(config.PWD))
+	return filepath.Join(os.TempDir(), "moogle-mod-manager-"+hex.EncodeToString(h[:8])+".sock")
+}
+
+// Send hands the args to the running instance. It fails when no instance is listening.
+func Send(args []string) (err error) {
+	var (
+		c    net.Conn
+		b    []byte
+		resp string
+	)
+	if c, err = net.DialTimeout("unix", socketPath(), dialTimeout); err != nil {
+		return
+	}
+	defer func() { _ = c.Close() }()
+	_ = c.SetDeadline(time.Now().Add(dialTimeout))
+	if b, err = json.Marshal(args); err != nil {
+		return
+	}
+	if _, err = c.Write(append(b, '\n')); err != nil {
+		return
+	}
+	if resp, err = bufio.NewReader(c).ReadString('\n'); err != nil {
+		return
+	}
+	if resp != "ok\n" {
+		return fmt.Errorf("the running instance did not accept the arguments")
+	}
+	return nil
+}
+
+// Listen calls handle with the args of every later launch that Sends them. A socket left behind by an instance that
+// crashed is replaced.
+func Listen(handle func(args []string)) (io.Closer, error) {
+	p := socketPath()
+	if c, err := net.DialTimeout("unix", p, dialTimeout); err == nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("another instance is already listening on %s", p)
+	}
+	_ = os.Remove(p)
+	l, err := net.Listen("unix", p)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(c, handle)
+		}
+	}()
+	return l, nil
+}
+
+func serve(c net.Conn, handle func(args []string)) {
+	defer func() { _ = c.Close() }()
+	_ = c.SetDeadline(time.Now().Add(dialTimeout))
+	var args []string
+	line, err := bufio.NewReader(c).ReadBytes('\n')
+	if err != nil || json.Unmarshal(line, &args) != nil {
+		return
+	}
+	_, _ = c.Write([]byte("ok\n"))
+	handle(args)
+}
@@ -0,0 +1,45 @@
+package ipc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	lockName = ".moogle.lock"
+	// forwardTimeout is how long a launch waits for the running instance to start listening
+	forwardTimeout = 10 * time.Second
+)
+
+var ErrLocked = errors.New("another instance is running")
+
+// Lock makes this the only instance running from config.PWD until the returned file is closed or the process exits.
+// It returns ErrLocked when another instance holds the lock.
+func Lock() (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(config.PWD, lockName), os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the instance lock: %v", err)
+	}
+	if err = lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	_ = f.Truncate(0)
+	_, _ = fmt.Fprintf(f, "%d\n", os.Getpid())
+	return f, nil
+}
+
+// Forward hands the args to the instance holding the lock, waiting for it to listen if it has only just started
+func Forward(args []string) (err error) {
+	deadline := time.Now().Add(forwardTimeout)
+	for {
+		if err = Send(args); err == nil || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
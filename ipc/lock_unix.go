@@ -0,0 +1,18 @@
+//go:build !windows
+
+package ipc
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockFile(f *os.File) error {
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if err == syscall.EWOULDBLOCK {
+			return ErrLocked
+		}
+		return err
+	}
+	return nil
+}
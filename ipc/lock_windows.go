@@ -0,0 +1,17 @@
+package ipc
+
+import (
+	"golang.org/x/sys/windows"
+	"os"
+)
+
+func lockFile(f *os.File) error {
+	ol := new(windows.Overlapped)
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol); err != nil {
+		if err == windows.ERROR_LOCK_VIOLATION {
+			return ErrLocked
+		}
+		return err
+	}
+	return nil
+}
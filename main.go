@@ -1,27 +1,59 @@
 package main
 
 import (
+	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/dialog"
 	"github.com/Xuanwo/go-locale"
 	"github.com/kiamev/moogle-mod-manager/browser"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/ipc"
 	"github.com/kiamev/moogle-mod-manager/mods/managed"
 	"github.com/kiamev/moogle-mod-manager/mods/managed/authored"
+	"github.com/kiamev/moogle-mod-manager/protocol"
+	"github.com/kiamev/moogle-mod-manager/steam"
 	config_installer "github.com/kiamev/moogle-mod-manager/ui/config-installer"
 	"github.com/kiamev/moogle-mod-manager/ui/game-select"
+	"github.com/kiamev/moogle-mod-manager/ui/links"
 	"github.com/kiamev/moogle-mod-manager/ui/local"
 	"github.com/kiamev/moogle-mod-manager/ui/menu"
 	mod_author "github.com/kiamev/moogle-mod-manager/ui/mod-author"
+	"github.com/kiamev/moogle-mod-manager/ui/repair"
 	"github.com/kiamev/moogle-mod-manager/ui/state"
+	"os"
 )
 
 func main() {
+	args := os.Args[1:]
+	lock, lockErr := ipc.Lock()
+	if lockErr == ipc.ErrLocked {
+		// The running instance opens the links and comes to the front
+		if err := ipc.Forward(args); err != nil {
+			println(err.Error())
+		}
+		return
+	} else if lockErr == nil {
+		defer func() { _ = lock.Close() }()
+	}
+
 	state.App = app.New()
 	state.Window = state.App.NewWindow("Moogle Mod Manager " + browser.Version)
 	state.Window.Resize(fyne.NewSize(800, 850))
+	if lockErr != nil {
+		dialog.ShowError(lockErr, state.Window)
+	}
+	// The games' dirs are only looked for once the mods are loaded, so a dir that has mods' files is not changed
 	if err := managed.Initialize(); err != nil {
 		dialog.ShowError(err, state.Window)
+	} else if set, err := steam.Prefill(managed.HasInstalledFiles); err != nil {
+		dialog.ShowError(err, state.Window)
+	} else if len(set) > 0 {
+		found := "These games were found and their dirs set:\n"
+		for _, game := range set {
+			found += fmt.Sprintf("\n%s: %s", config.GameNameString(game), config.GetModDir(game))
+		}
+		dialog.ShowInformation("Games Found", found, state.Window)
 	}
 	if err := authored.Initialize(); err != nil {
 		dialog.ShowError(err, state.Window)
@@ -44,5 +76,19 @@ func main() {
 	state.RegisterScreen(state.ConfigInstaller, config_installer.New())
 
 	state.ShowScreen(state.None)
+	repair.Show()
+
+	if l, err := ipc.Listen(func(args []string) {
+		state.Window.RequestFocus()
+		links.Open(args)
+	}); err != nil {
+		println(err.Error())
+	} else {
+		defer func() { _ = l.Close() }()
+	}
+	if err := protocol.Register(); err != nil {
+		println(err.Error())
+	}
+	links.Open(args)
 	state.Window.ShowAndRun()
 }
@@ -0,0 +1,58 @@
+package io
+
+import (
+	"github.com/kiamev/moogle-mod-manager/mods"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+func ExpandDirs(dirs []*mods.ModDir, modDir string) (files []*mods.ModFile, err error) {
+	var fs []*mods.ModFile
+	for _, d := range dirs {
+		if fs, err = ExpandDir(d, modDir); err != nil {
+			return
+		}
+		files = append(files, fs...)
+	}
+	return
+}
+
+// ExpandDir lists the files a ModDir copies. Only the files directly in From are included unless the dir is Recursive,
+// in which case sub-directories keep their structure under To.
+func ExpandDir(d *mods.ModDir, modDir string) (files []*mods.ModFile, err error) {
+	from := path.Join(modDir, d.From)
+	if !d.Recursive {
+		var fis []os.FileInfo
+		if fis, err = ioutil.ReadDir(from); err != nil {
+			return
+		}
+		for _, fi := range fis {
+			if !fi.IsDir() {
+				files = append(files, &mods.ModFile{
+					From: path.Join(d.From, fi.Name()),
+					To:   d.To,
+				})
+			}
+		}
+		return
+	}
+
+	err = filepath.Walk(from, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(from, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		files = append(files, &mods.ModFile{
+			From: path.Join(d.From, rel),
+			To:   path.Join(d.To, path.Dir(rel)),
+		})
+		return nil
+	})
+	return
+}
@@ -1,49 +1,181 @@
 package io
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"github.com/kiamev/moogle-mod-manager/config"
 	"github.com/kiamev/moogle-mod-manager/mods"
-	"io/ioutil"
+	goio "io"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 )
 
-func MoveFiles(files []mods.ModFile, modDir string, game config.Game) (err error) {
+// ToFile is the file, relative to the game's directory, that a mod file is copied to. A ModFile's To is the directory
+// the file is placed in.
+func ToFile(f *mods.ModFile) string {
+	return path.Join(f.To, path.Base(f.From))
+}
+
+// InstalledFile is a file a mod placed in the game's directory. Replaced files have a backup of the game's original
+// to restore on uninstall, files that are not replaced were added by the mod and are deleted.
+type InstalledFile struct {
+	File       string `json:"File"`
+	Replaced   bool   `json:"Replaced"`
+	Hash       string `json:"Hash,omitempty"`
+	BackupHash string `json:"BackupHash,omitempty"`
+	// Dirs are the dirs, deepest first, that were created for the file. They are removed with it when they are empty,
+	// any other dir was the game's.
+	Dirs []string `json:"Dirs,omitempty"`
+}
+
+// RevertMoveFiles takes the files out of the game's directory, restoring the game's originals from their backups.
+// reverted, if set, is called with each file once it is reverted so the caller can forget it, which lets a revert that
+// failed part way be run again without touching the files already reverted. A file the mod added is only deleted
+// while it is still the file the mod installed, and a replaced file whose backup is gone is left in place.
+func RevertMoveFiles(files []*InstalledFile, game config.Game, reverted func(f *InstalledFile)) (err error) {
 	var (
 		toDir     = config.GetModDir(game)
 		backupDir = config.GetBackupDir(game)
+		to        string
+		backup    string
+		matches   bool
 	)
 	for _, f := range files {
-		if err = os.Rename(path.Join(toDir, f.To), path.Join(backupDir, f.To)); err != nil {
-			break
+		to = path.Join(toDir, f.File)
+		backup = path.Join(backupDir, f.File)
+		if _, err = os.Stat(backup); f.Replaced && err == nil {
+			if err = os.MkdirAll(path.Dir(to), 0777); err != nil {
+				return
+			}
+			if err = os.Rename(backup, to); err != nil {
+				return
+			}
+			removeEmptyDirs(path.Dir(backup), backupDir)
+		} else if !f.Replaced {
+			if matches, err = isInstalled(to, f.Hash); err != nil {
+				return
+			}
+			if matches {
+				if err = os.Remove(to); err != nil {
+					return
+				}
+				removeDirs(toDir, f.Dirs)
+			}
 		}
-		if err = copy(path.Join(modDir, f.From), path.Join(toDir, f.To)); err != nil {
-			break
+		err = nil
+		if reverted != nil {
+			reverted(f)
 		}
 	}
 	return
 }
 
-func RevertMoveFiles(files []string, game config.Game) (err error) {
-	var (
-		toDir     = config.GetModDir(game)
-		backupDir = config.GetBackupDir(game)
-	)
-	for _, f := range files {
-		if err = os.Remove(path.Join(toDir, f)); err != nil {
-			break
+// isInstalled is whether the file exists and is still the one installed, which is assumed of files installed before
+// hashes were recorded
+func isInstalled(file string, hash string) (bool, error) {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if hash == "" {
+		return true, nil
+	}
+	h, err := hashFile(file)
+	return h == hash, err
+}
+
+// BackupFiles are the files, relative to the game's directory, that have a backup of the game's original in the game's
+// backup dir
+func BackupFiles(game config.Game) (files []string, err error) {
+	backupDir := config.GetBackupDir(game)
+	err = filepath.Walk(backupDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := filepath.Rel(backupDir, p)
+		if err != nil {
+			return err
+		}
+		f = filepath.ToSlash(f)
+		// The journal and the temp files it is saved through are not backups
+		if !strings.HasPrefix(f, journalName) {
+			files = append(files, f)
 		}
-		if err = os.Rename(path.Join(backupDir, f), path.Join(toDir, f)); err != nil {
+		return nil
+	})
+	return
+}
+
+// missingDirs are the dirs, deepest first, that do not exist yet to place the file under root
+func missingDirs(root string, file string) (dirs []string) {
+	for d := path.Dir(file); d != "." && d != "/"; d = path.Dir(d) {
+		if _, err := os.Stat(path.Join(root, d)); err == nil {
 			break
 		}
+		dirs = append(dirs, d)
 	}
 	return
 }
 
-func copy(from, to string) error {
-	input, err := ioutil.ReadFile(from)
+// removeDirs removes the dirs under root that are empty, in order
+func removeDirs(root string, dirs []string) {
+	for _, d := range dirs {
+		if !mods.IsRelative(d) || os.Remove(path.Join(root, d)) != nil {
+			return
+		}
+	}
+}
+
+// removeEmptyDirs removes dir and its parents until one is not empty or root is reached
+func removeEmptyDirs(dir string, root string) {
+	root = path.Clean(root)
+	for dir = path.Clean(dir); dir != root && strings.HasPrefix(dir, root+"/"); dir = path.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// copy streams the file to its destination, returning the SHA-256 of what was copied
+func copy(from, to string) (sum string, err error) {
+	var in, out *os.File
+	if in, err = os.Open(from); err != nil {
+		return
+	}
+	defer func() { _ = in.Close() }()
+	if out, err = os.Create(to); err != nil {
+		return
+	}
+	defer func() {
+		if cErr := out.Close(); err == nil {
+			err = cErr
+		}
+	}()
+	h := sha256.New()
+	if _, err = goio.Copy(goio.MultiWriter(out, h), in); err != nil {
+		return
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
 	if err != nil {
-		return err
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err = goio.Copy(h, f); err != nil {
+		return "", err
 	}
-	return ioutil.WriteFile(to, input, 0777)
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
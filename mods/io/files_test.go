@@ -0,0 +1,69 @@
+package io
+
+import (
+	"github.com/kiamev/moogle-mod-manager/config"
+	"reflect"
+	"testing"
+)
+
+func TestRevertMoveFiles(t *testing.T) {
+	modDir := setupInstall(t)
+	tx, err := BeginInstall(testGame, "mod", testInstall)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.MoveFiles(testFiles, modDir); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	files := tx.Installed()
+	var reverted []string
+	if err = RevertMoveFiles(files, testGame, func(f *InstalledFile) { reverted = append(reverted, f.File) }); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reverted, testInstall) {
+		t.Errorf("reverted %v, want %v", reverted, testInstall)
+	}
+	if got := snapshot(t); !reflect.DeepEqual(got, original) {
+		t.Errorf("after reverting:\n got %v\nwant %v", got, original)
+	}
+	// Reverting again changes nothing
+	if err = RevertMoveFiles(files, testGame, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot(t); !reflect.DeepEqual(got, original) {
+		t.Errorf("after reverting twice:\n got %v\nwant %v", got, original)
+	}
+}
+
+func TestRevertMoveFilesKeepsChangedFiles(t *testing.T) {
+	modDir := setupInstall(t)
+	tx, err := BeginInstall(testGame, "mod", testInstall)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.MoveFiles(testFiles, modDir); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, config.GetModDir(testGame), map[string]string{"new/b.txt": "changed by the user"})
+	if err = RevertMoveFiles(tx.Installed(), testGame, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"a.txt":       "game a",
+		"new/":        "",
+		"new/b.txt":   "changed by the user",
+		"kept/":       "",
+		"kept/c.txt":  "game c",
+		"empty/":      "",
+		"backup-dir/": "",
+	}
+	if got := snapshot(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("after reverting:\n got %v\nwant %v", got, want)
+	}
+}
@@ -0,0 +1,247 @@
+package io
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/mods"
+	"github.com/kiamev/moogle-mod-manager/persist"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+const (
+	journalName = "journal.json"
+	// damagedExt is added to a journal that cannot be read when it is set aside
+	damagedExt = ".damaged"
+)
+
+// Transaction installs files into a game's directory so that they can all be rolled back to the exact pre-install
+// state. Every file it will touch is written to a journal in the game's backup dir before the game's directory is
+// modified, and the journal is updated after each backup and copy. A journal left behind by a crash is rolled back
+// by RecoverInstall.
+type Transaction struct {
+	game    config.Game
+	journal *journal
+	entries map[string]*journalEntry
+}
+
+type journal struct {
+	ModID   string          `json:"ModID"`
+	Entries []*journalEntry `json:"Entries"`
+}
+
+type journalEntry struct {
+	File       string `json:"File"`
+	Replaces   bool   `json:"Replaces"`
+	BackedUp   bool   `json:"BackedUp"`
+	Copied     bool   `json:"Copied"`
+	Hash       string `json:"Hash,omitempty"`
+	BackupHash string `json:"BackupHash,omitempty"`
+	// Dirs are the dirs the install creates for the file, deepest first
+	Dirs []string `json:"Dirs,omitempty"`
+}
+
+func BeginInstall(game config.Game, modID string, files []string) (t *Transaction, err error) {
+	var (
+		toDir     = config.GetModDir(game)
+		backupDir = config.GetBackupDir(game)
+		jf        = path.Join(backupDir, journalName)
+	)
+	if _, err = os.Stat(jf); err == nil {
+		return nil, fmt.Errorf("another install is in progress for %s", config.GameNameString(game))
+	}
+	t = &Transaction{
+		game:    game,
+		journal: &journal{ModID: modID},
+		entries: make(map[string]*journalEntry),
+	}
+	for _, f := range files {
+		if !mods.IsRelative(f) {
+			return nil, fmt.Errorf("%s is outside the game's directory", f)
+		}
+		if _, ok := t.entries[f]; ok {
+			return nil, fmt.Errorf("%s is installed more than once", f)
+		}
+		e := &journalEntry{File: f}
+		if _, err = os.Stat(path.Join(toDir, f)); err == nil {
+			e.Replaces = true
+			if _, err = os.Stat(path.Join(backupDir, f)); err == nil {
+				return nil, fmt.Errorf("a backup of %s already exists", f)
+			}
+		} else {
+			e.Dirs = missingDirs(toDir, f)
+		}
+		t.journal.Entries = append(t.journal.Entries, e)
+		t.entries[f] = e
+	}
+	if err = os.MkdirAll(backupDir, 0777); err != nil {
+		return nil, err
+	}
+	if err = t.save(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *Transaction) MoveFiles(files []*mods.ModFile, modDir string) (err error) {
+	var (
+		toDir     = config.GetModDir(t.game)
+		backupDir = config.GetBackupDir(t.game)
+		to        string
+		e         *journalEntry
+		ok        bool
+	)
+	for _, f := range files {
+		to = ToFile(f)
+		if e, ok = t.entries[to]; !ok {
+			return fmt.Errorf("%s is not part of the install", to)
+		}
+		if !mods.IsRelative(f.From) {
+			return fmt.Errorf("%s is outside the mod's files", f.From)
+		}
+		if e.Replaces {
+			if err = os.MkdirAll(path.Dir(path.Join(backupDir, to)), 0777); err != nil {
+				return
+			}
+			if err = os.Rename(path.Join(toDir, to), path.Join(backupDir, to)); err != nil {
+				return
+			}
+			e.BackedUp = true
+			if e.BackupHash, err = hashFile(path.Join(backupDir, to)); err != nil {
+				return
+			}
+			if err = t.save(); err != nil {
+				return
+			}
+		} else if err = os.MkdirAll(path.Dir(path.Join(toDir, to)), 0777); err != nil {
+			return
+		}
+		if e.Hash, err = copy(path.Join(modDir, f.From), path.Join(toDir, to)); err != nil {
+			return
+		}
+		e.Copied = true
+		if err = t.save(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Installed is the files the transaction placed in the game's directory
+func (t *Transaction) Installed() []*InstalledFile {
+	installed := make([]*InstalledFile, 0, len(t.journal.Entries))
+	for _, e := range t.journal.Entries {
+		if e.Copied {
+			installed = append(installed, &InstalledFile{
+				File:       e.File,
+				Replaced:   e.Replaces,
+				Hash:       e.Hash,
+				BackupHash: e.BackupHash,
+				Dirs:       e.Dirs,
+			})
+		}
+	}
+	return installed
+}
+
+func (t *Transaction) Commit() error {
+	return os.Remove(t.journalFile())
+}
+
+// Rollback restores every file the transaction touched, newest first, and removes the journal
+func (t *Transaction) Rollback() (err error) {
+	var (
+		toDir     = config.GetModDir(t.game)
+		backupDir = config.GetBackupDir(t.game)
+	)
+	for i := len(t.journal.Entries) - 1; i >= 0; i-- {
+		if err = t.journal.Entries[i].rollback(toDir, backupDir); err != nil {
+			return fmt.Errorf("failed to roll back %s: %v", t.journal.Entries[i].File, err)
+		}
+	}
+	return os.Remove(t.journalFile())
+}
+
+// RecoverInstall rolls back an install of the game that did not finish, e.g. because the application crashed. An
+// install is finished once the caller has recorded its files, which recorded reports, so its journal is only removed.
+func RecoverInstall(game config.Game, recorded func(modID string) bool) (err error) {
+	var (
+		jf = path.Join(config.GetBackupDir(game), journalName)
+		b  []byte
+		t  = &Transaction{game: game}
+	)
+	if _, err = os.Stat(jf); err != nil {
+		return nil
+	}
+	if b, err = ioutil.ReadFile(jf); err != nil {
+		return fmt.Errorf("failed to read %s: %v", jf, err)
+	}
+	if err = json.Unmarshal(b, &t.journal); err != nil {
+		return fmt.Errorf("failed to read %s: %v", jf, err)
+	}
+	if t.journal.ModID != "" && recorded(t.journal.ModID) {
+		return t.Commit()
+	}
+	if err = t.Rollback(); err != nil {
+		return fmt.Errorf("failed to roll back the interrupted install of %s: %v", t.journal.ModID, err)
+	}
+	return nil
+}
+
+// DiscardDamagedJournal sets the game's journal aside when it cannot be read, so the install it was for is no longer
+// recovered. The files the install touched are left as they are.
+func DiscardDamagedJournal(game config.Game) error {
+	var (
+		jf = path.Join(config.GetBackupDir(game), journalName)
+		j  *journal
+	)
+	b, err := ioutil.ReadFile(jf)
+	if err != nil || json.Unmarshal(b, &j) == nil {
+		return nil
+	}
+	return os.Rename(jf, jf+damagedExt)
+}
+
+func (e *journalEntry) rollback(toDir, backupDir string) (err error) {
+	var (
+		to     = path.Join(toDir, e.File)
+		backup = path.Join(backupDir, e.File)
+	)
+	if !e.Replaces {
+		if err = os.Remove(to); err != nil && !os.IsNotExist(err) {
+			return
+		}
+		removeDirs(toDir, e.Dirs)
+		return nil
+	}
+	if !e.BackedUp {
+		// The crash may have happened between the backup and the journal update
+		if _, err = os.Stat(to); err == nil {
+			return nil
+		}
+		if _, err = os.Stat(backup); err != nil {
+			return nil
+		}
+	}
+	if err = os.Remove(to); err != nil && !os.IsNotExist(err) {
+		return
+	}
+	if err = os.Rename(backup, to); err == nil {
+		removeEmptyDirs(path.Dir(backup), backupDir)
+	}
+	return
+}
+
+func (t *Transaction) journalFile() string {
+	return path.Join(config.GetBackupDir(t.game), journalName)
+}
+
+func (t *Transaction) save() (err error) {
+	var b []byte
+	if b, err = json.MarshalIndent(t.journal, "", "\t"); err != nil {
+		return
+	}
+	return persist.WriteFile(t.journalFile(), b)
+}
@@ -0,0 +1,280 @@
+package io
+
+import (
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/mods"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testGame = config.Game(0)
+
+var (
+	// testFiles are a mod's files, the first replaces a game file, the second is added in a new dir and the third in a
+	// dir the game already had
+	testFiles = []*mods.ModFile{
+		{From: "a.txt", To: "."},
+		{From: "new/b.txt", To: "new"},
+		{From: "d.txt", To: "empty"},
+	}
+	testInstall = []string{"a.txt", "new/b.txt", "empty/d.txt"}
+	original    = map[string]string{
+		"a.txt":       "game a",
+		"kept/":       "",
+		"kept/c.txt":  "game c",
+		"empty/":      "",
+		"backup-dir/": "",
+	}
+	installed = map[string]string{
+		"a.txt":        "mod a",
+		"new/":         "",
+		"new/b.txt":    "mod b",
+		"kept/":        "",
+		"kept/c.txt":   "game c",
+		"empty/":       "",
+		"empty/d.txt":  "mod d",
+		"backup-dir/":  "",
+		"backup/a.txt": "game a",
+	}
+)
+
+// setupInstall makes a game dir with the original files and a mod dir with testFiles, returning the mod dir
+func setupInstall(t *testing.T) string {
+	t.Helper()
+	config.PWD = t.TempDir()
+	writeFiles(t, config.GetModDir(testGame), map[string]string{"a.txt": "game a", "kept/c.txt": "game c", "empty/": ""})
+	if err := os.MkdirAll(config.GetBackupDir(testGame), 0755); err != nil {
+		t.Fatal(err)
+	}
+	modDir := filepath.Join(t.TempDir(), "mod")
+	writeFiles(t, modDir, map[string]string{"a.txt": "mod a", "new/b.txt": "mod b", "d.txt": "mod d"})
+	return modDir
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for f, content := range files {
+		p := path.Join(dir, f)
+		if f[len(f)-1] == '/' {
+			if err := os.MkdirAll(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// snapshot is the game dir's files and dirs, dirs ending in /, with the backups under backup/ and the backup dir
+// itself as backup-dir/
+func snapshot(t *testing.T) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	read := func(root string, prefix string) {
+		err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil || p == root {
+				return err
+			}
+			rel, _ := filepath.Rel(root, p)
+			rel = prefix + filepath.ToSlash(rel)
+			if info.IsDir() {
+				files[rel+"/"] = ""
+				return nil
+			}
+			b, err := ioutil.ReadFile(p)
+			files[rel] = string(b)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	read(config.GetModDir(testGame), "")
+	if _, err := os.Stat(config.GetBackupDir(testGame)); err == nil {
+		files["backup-dir/"] = ""
+		read(config.GetBackupDir(testGame), "backup/")
+	}
+	return files
+}
+
+func TestRecoverInstall(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		crash    func(t *testing.T, tx *Transaction, modDir string)
+		recorded bool
+		want     map[string]string
+	}{
+		{
+			name:  "nothing moved",
+			crash: func(*testing.T, *Transaction, string) {},
+			want:  original,
+		},
+		{
+			name: "backed up before the journal was saved",
+			crash: func(t *testing.T, _ *Transaction, _ string) {
+				if err := os.Rename(path.Join(config.GetModDir(testGame), "a.txt"), path.Join(config.GetBackupDir(testGame), "a.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: original,
+		},
+		{
+			name: "backed up",
+			crash: func(t *testing.T, tx *Transaction, _ string) {
+				if err := os.Rename(path.Join(config.GetModDir(testGame), "a.txt"), path.Join(config.GetBackupDir(testGame), "a.txt")); err != nil {
+					t.Fatal(err)
+				}
+				tx.entries["a.txt"].BackedUp = true
+				if err := tx.save(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: original,
+		},
+		{
+			name: "copied one file",
+			crash: func(t *testing.T, tx *Transaction, modDir string) {
+				if err := tx.MoveFiles(testFiles[:1], modDir); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: original,
+		},
+		{
+			name: "copied before the journal was saved",
+			crash: func(t *testing.T, tx *Transaction, modDir string) {
+				if err := tx.MoveFiles(testFiles[:1], modDir); err != nil {
+					t.Fatal(err)
+				}
+				writeFiles(t, config.GetModDir(testGame), map[string]string{"new/b.txt": "mod b"})
+			},
+			want: original,
+		},
+		{
+			name: "copied every file",
+			crash: func(t *testing.T, tx *Transaction, modDir string) {
+				if err := tx.MoveFiles(testFiles, modDir); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: original,
+		},
+		{
+			name: "copied every file and recorded them",
+			crash: func(t *testing.T, tx *Transaction, modDir string) {
+				if err := tx.MoveFiles(testFiles, modDir); err != nil {
+					t.Fatal(err)
+				}
+			},
+			recorded: true,
+			want:     installed,
+		},
+		{
+			name: "committed",
+			crash: func(t *testing.T, tx *Transaction, modDir string) {
+				if err := tx.MoveFiles(testFiles, modDir); err != nil {
+					t.Fatal(err)
+				}
+				if err := tx.Commit(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: installed,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			modDir := setupInstall(t)
+			tx, err := BeginInstall(testGame, "mod", testInstall)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.crash(t, tx, modDir)
+
+			var asked string
+			if err = RecoverInstall(testGame, func(modID string) bool {
+				asked = modID
+				return tt.recorded
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if got := snapshot(t); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("after recovering:\n got %v\nwant %v", got, tt.want)
+			}
+			if asked != "" && asked != "mod" {
+				t.Errorf("RecoverInstall asked whether %s was recorded", asked)
+			}
+			// Recovering again finds nothing to do
+			if err = RecoverInstall(testGame, func(string) bool { return tt.recorded }); err != nil {
+				t.Fatal(err)
+			}
+			if got := snapshot(t); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("after recovering twice:\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	modDir := setupInstall(t)
+	tx, err := BeginInstall(testGame, "mod", testInstall)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.MoveFiles(testFiles, modDir); err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot(t); got["a.txt"] != "mod a" || got["new/b.txt"] != "mod b" || got["backup/a.txt"] != "game a" {
+		t.Fatalf("installed files = %v", got)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot(t); !reflect.DeepEqual(got, original) {
+		t.Errorf("after rolling back:\n got %v\nwant %v", got, original)
+	}
+}
+
+func TestBeginInstallRefuses(t *testing.T) {
+	setupInstall(t)
+	for _, files := range [][]string{
+		{"../outside.txt"},
+		{"/abs.txt"},
+		{"a.txt", "a.txt"},
+	} {
+		if _, err := BeginInstall(testGame, "mod", files); err == nil {
+			t.Errorf("BeginInstall(%v) succeeded", files)
+		}
+	}
+
+	writeFiles(t, config.GetBackupDir(testGame), map[string]string{"a.txt": "older backup"})
+	if _, err := BeginInstall(testGame, "mod", []string{"a.txt"}); err == nil {
+		t.Error("BeginInstall replaced a file that already has a backup")
+	}
+	if err := os.Remove(path.Join(config.GetBackupDir(testGame), "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := BeginInstall(testGame, "mod", []string{"a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BeginInstall(testGame, "other", []string{"new/b.txt"}); err == nil {
+		t.Error("BeginInstall started a second install of the game")
+	}
+	got := snapshot(t)
+	if _, ok := got["backup/"+journalName]; !ok {
+		t.Error("the started install has no journal")
+	}
+	delete(got, "backup/"+journalName)
+	if !reflect.DeepEqual(got, original) {
+		t.Errorf("refused installs changed the game:\n got %v\nwant %v", got, original)
+	}
+}
@@ -0,0 +1,73 @@
+package io
+
+import (
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"os"
+	"path"
+)
+
+type Change string
+
+const (
+	Modified Change = "modified"
+	Missing  Change = "missing"
+)
+
+// FileChange is an installed file, or the backup of the game file it replaced, that no longer matches what was
+// recorded at install. Files installed before hashes were recorded are not verified.
+type FileChange struct {
+	File   string
+	Backup bool
+	Change Change
+}
+
+func (c *FileChange) String() string {
+	if c.Backup {
+		return fmt.Sprintf("%s (backup) is %s", c.File, c.Change)
+	}
+	return fmt.Sprintf("%s is %s", c.File, c.Change)
+}
+
+func Verify(files []*InstalledFile, game config.Game) (changes []*FileChange, err error) {
+	var (
+		toDir     = config.GetModDir(game)
+		backupDir = config.GetBackupDir(game)
+		c         *FileChange
+	)
+	for _, f := range files {
+		if c, err = verify(path.Join(toDir, f.File), f.Hash); err != nil {
+			return
+		} else if c != nil {
+			c.File = f.File
+			changes = append(changes, c)
+		}
+		if f.Replaced {
+			if c, err = verify(path.Join(backupDir, f.File), f.BackupHash); err != nil {
+				return
+			} else if c != nil {
+				c.File = f.File
+				c.Backup = true
+				changes = append(changes, c)
+			}
+		}
+	}
+	return
+}
+
+func verify(file string, expected string) (*FileChange, error) {
+	if expected == "" {
+		return nil, nil
+	}
+	h, err := hashFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &FileChange{Change: Missing}, nil
+		}
+		return nil, err
+	}
+	if h != expected {
+		return &FileChange{Change: Modified}, nil
+	}
+	return nil, nil
+}
@@ -1,56 +1,38 @@
 package authored
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/kiamev/moogle-mod-manager/config"
-	"io/ioutil"
+	"github.com/kiamev/moogle-mod-manager/persist"
 	"os"
 	"path"
+	"sync"
 )
 
 const file = "authored.json"
 
-var lookup = make(map[string]string)
+var (
+	lookup = make(map[string]string)
+	mutex  sync.Mutex
+)
 
 func Initialize() (err error) {
-	f := path.Join(config.PWD, file)
-	if _, err = os.Stat(f); err != nil {
-		return nil
-	}
-	var b []byte
-	if b, err = ioutil.ReadFile(f); err != nil {
-		return fmt.Errorf("failed to read %s: %v", file, err)
-	}
-	if err = json.Unmarshal(b, &lookup); err != nil {
+	if err = persist.LoadJSON(path.Join(config.PWD, file), &lookup); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to read %s: %v", file, err)
 	}
 	return nil
 }
 
 func GetDir(modID string) (dir string, found bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
 	dir, found = lookup[modID]
 	return
 }
 
 func SetDir(modID string, dir string) (err error) {
+	mutex.Lock()
+	defer mutex.Unlock()
 	lookup[modID] = dir
-	var (
-		b []byte
-		f *os.File
-	)
-
-	if b, err = json.MarshalIndent(&lookup, "", "\t"); err != nil {
-		return fmt.Errorf("failed to prepare %s: %v", file, err)
-	}
-
-	if f, err = os.Create(path.Join(config.PWD, file)); err != nil {
-		return fmt.Errorf("failed to create %s: %v", file, err)
-	}
-	defer func() { f.Close() }()
-
-	if _, err = f.Write(b); err != nil {
-		return fmt.Errorf("failed to write %s: %v", file, err)
-	}
-	return nil
+	return persist.SaveJSON(path.Join(config.PWD, file), &lookup)
 }
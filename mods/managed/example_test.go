@@ -0,0 +1,125 @@
+package managed
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/mods"
+	"github.com/kiamev/moogle-mod-manager/mods/managed/model"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestInstallExampleMod adds and enables example/mod.json with the Normal Cosmog choice, serving its downloads from
+// example/mod, then disables it again
+func TestInstallExampleMod(t *testing.T) {
+	var (
+		b   []byte
+		mod *mods.Mod
+		err error
+	)
+	if b, err = ioutil.ReadFile("../../example/mod.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(b, &mod); err != nil {
+		t.Fatal(err)
+	}
+	if s := mod.Validate(); s != "" {
+		t.Fatalf("the example is not valid:\n%s", s)
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir("../../example/mod")))
+	defer srv.Close()
+	for _, dl := range mod.Downloadables {
+		for i, s := range dl.Sources {
+			dl.Sources[i] = srv.URL + "/" + path.Base(s)
+		}
+	}
+
+	config.PWD = t.TempDir()
+	offline := config.Get().Offline
+	config.Get().Offline = false
+	defer func() { config.Get().Offline = offline }()
+	if err = Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	game, _ := config.FromString("VI")
+	gameDir := config.GetModDir(game)
+	if err = os.MkdirAll(path.Join(gameDir, "assets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path.Join(gameDir, "assets", "Mog.png"), []byte("the game's Mog"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = AddMod(game, model.NewTrackerMod(game, mod)); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		normal    = mod.Configurations[0].Choices[0]
+		toInstall = []*mods.DownloadFiles{normal.DownloadFiles}
+		checked   bool
+	)
+	// While the files are copied the mods are listed and enabling the mod again is refused
+	progress := func(status string, _ float64) {
+		if checked || status != "Installing "+mod.Name {
+			return
+		}
+		checked = true
+		if len(GetMods(game)) != 1 {
+			t.Errorf("the mods listed while enabling are %v", GetMods(game))
+		}
+		if err := EnableMod(context.Background(), game, mod.ID, toInstall, nil, nil, nil); err == nil {
+			t.Error("the mod was enabled again while it was being enabled")
+		}
+	}
+	if err = EnableMod(context.Background(), game, mod.ID, toInstall, nil, progress, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !checked {
+		t.Error("enabling did not report installing")
+	}
+	want := []string{"Cosmog.png", "Mog.png", "Molulu.png", "Mugmug.png", "moogles_to_manage.txt"}
+	if got := listDir(t, path.Join(gameDir, "assets")); !reflect.DeepEqual(got, want) {
+		t.Errorf("enabling installed %v, want %v", got, want)
+	}
+	if b, _ = ioutil.ReadFile(path.Join(gameDir, "assets", "Cosmog.png")); len(b) != 5584 {
+		t.Errorf("Cosmog.png is not the normal one, it has %d bytes", len(b))
+	}
+	if len(Issues()) != 0 {
+		t.Errorf("enabling left issues: %v", Issues())
+	}
+	if !HasInstalledFiles(game) {
+		t.Error("the game has no installed files after enabling")
+	}
+
+	if err = DisableMod(game, mod.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := listDir(t, path.Join(gameDir, "assets")); !reflect.DeepEqual(got, []string{"Mog.png"}) {
+		t.Errorf("disabling left %v", got)
+	}
+	if b, _ = ioutil.ReadFile(path.Join(gameDir, "assets", "Mog.png")); string(b) != "the game's Mog" {
+		t.Errorf("disabling did not restore the game's Mog.png, it is %d bytes", len(b))
+	}
+	if HasInstalledFiles(game) {
+		t.Error("the game has installed files after disabling")
+	}
+}
+
+func listDir(t *testing.T, dir string) (names []string) {
+	t.Helper()
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range fis {
+		names = append(names, filepath.Base(fi.Name()))
+	}
+	return
+}
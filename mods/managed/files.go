@@ -1,18 +1,14 @@
 package managed
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/kiamev/moogle-mod-manager/config"
 	"github.com/kiamev/moogle-mod-manager/mods/io"
 	"io/ioutil"
+	"os"
 	"strings"
 )
 
-const (
-	managedXmlName = "managed.json"
-)
-
 var (
 	managed = make(map[config.Game]*managedModsAndFiles)
 )
@@ -24,10 +20,11 @@ type managedModsAndFiles struct {
 
 type modFiles struct {
 	ModID string
-	Files []string
+	Files []*io.InstalledFile
 }
 
-func AddModFiles(game config.Game, modID string, files []string) error {
+// addModFiles records the files the mod installed, which are saved with the next saveState
+func addModFiles(game config.Game, modID string, files []*io.InstalledFile) error {
 	m, ok := managed[game]
 	if !ok {
 		m = &managedModsAndFiles{AllFiles: make(map[string]bool)}
@@ -40,36 +37,110 @@ func AddModFiles(game config.Game, modID string, files []string) error {
 		}
 	}
 
-	if collisions := detectCollisions(m.AllFiles, files); len(collisions) > 0 {
+	toFiles := make([]string, len(files))
+	for i, f := range files {
+		toFiles[i] = f.File
+	}
+	if collisions := detectCollisions(m.AllFiles, toFiles); len(collisions) > 0 {
 		return fmt.Errorf("cannot enable mod as these files would collide: %s", strings.Join(collisions, ", "))
 	}
 
 	m.Mods = append(m.Mods, modFiles{ModID: modID, Files: files})
 	for _, f := range files {
-		m.AllFiles[f] = true
+		m.AllFiles[f.File] = true
 	}
-	return saveManagedJson()
+	return nil
 }
 
-func RemoveModFiles(game config.Game, modID string) error {
+// removeModFiles restores the game files the mod replaced and forgets its files, which is saved with the next
+// saveState. When it fails, the files that were already reverted are forgotten.
+func removeModFiles(game config.Game, modID string) error {
 	m, ok := managed[game]
 	if !ok {
 		return nil
 	}
+	for _, mf := range m.Mods {
+		if modID == mf.ModID {
+			files := append([]*io.InstalledFile(nil), mf.Files...)
+			if err := io.RevertMoveFiles(files, game, func(f *io.InstalledFile) {
+				forgetFile(game, modID, f.File)
+			}); err != nil {
+				return err
+			}
+			forgetModFiles(game, modID)
+			break
+		}
+	}
+	return nil
+}
+
+// forgetModFiles drops the record of the mod's files without touching the files
+func forgetModFiles(game config.Game, modID string) {
+	m, ok := managed[game]
+	if !ok {
+		return
+	}
 	for i, mf := range m.Mods {
 		if modID == mf.ModID {
 			m.Mods[i] = m.Mods[len(m.Mods)-1]
 			m.Mods = m.Mods[:len(m.Mods)-1]
-			if err := io.RevertMoveFiles(mf.Files, game); err != nil {
-				return err
-			}
 			for _, f := range mf.Files {
-				delete(m.AllFiles, f)
+				delete(m.AllFiles, f.File)
+			}
+			return
+		}
+	}
+}
+
+// VerifyMod reports the mod's installed files, and the backups of the game files they replaced, that were changed
+// since the mod was enabled
+func VerifyMod(game config.Game, modID string) ([]*io.FileChange, error) {
+	var files []*io.InstalledFile
+	stateMutex.Lock()
+	if m, ok := managed[game]; ok {
+		for _, mf := range m.Mods {
+			if modID == mf.ModID {
+				files = append(files, mf.Files...)
+				break
 			}
-			break
 		}
 	}
-	return saveManagedJson()
+	stateMutex.Unlock()
+	if len(files) == 0 {
+		return nil, nil
+	}
+	return io.Verify(files, game)
+}
+
+// HasInstalledFiles is whether mods installed files in the game's dir, or left backups of the game's files or an
+// interrupted install in its backup dir, which changing the game's dir would lose track of
+func HasInstalledFiles(game config.Game) bool {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	if m, ok := managed[game]; ok && len(m.Mods) > 0 {
+		return true
+	}
+	fis, err := ioutil.ReadDir(config.GetBackupDir(game))
+	return len(fis) > 0 || (err != nil && !os.IsNotExist(err))
+}
+
+// hasModFiles is whether the mod's installed files are recorded
+func hasModFiles(game config.Game, modID string) bool {
+	if m, ok := managed[game]; ok {
+		for _, mf := range m.Mods {
+			if modID == mf.ModID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getAllFiles(game config.Game) map[string]bool {
+	if m, ok := managed[game]; ok {
+		return m.AllFiles
+	}
+	return nil
 }
 
 func detectCollisions(managedFiles map[string]bool, modFiles []string) (collisions []string) {
@@ -81,11 +152,3 @@ func detectCollisions(managedFiles map[string]bool, modFiles []string) (collisio
 	}
 	return
 }
-
-func saveManagedJson() error {
-	b, err := json.MarshalIndent(managed, "", "\t")
-	if err != nil {
-		return err
-	}
-	return ioutil.WriteFile(managedXmlName, b, 0777)
-}
@@ -0,0 +1,254 @@
+package managed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/browser"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/decompressor"
+	"github.com/kiamev/moogle-mod-manager/mods"
+	"github.com/kiamev/moogle-mod-manager/mods/io"
+	"github.com/kiamev/moogle-mod-manager/mods/managed/model"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// downloadWorkers is how many downloads of a mod run at once
+	downloadWorkers = 3
+	// extractDir is where downloads are extracted in the temp dir, each into a dir named after its position
+	extractDir = "extracted"
+)
+
+type downloadedFiles struct {
+	dir   string
+	files []*mods.ModFile
+}
+
+// Progress reports the install's current step and how far along it is, from 0 to 1, or -1 when that is not known
+type Progress func(status string, done float64)
+
+// NewDownloadManager is a manager that downloads as many of a mod's downloads at once as installs do
+func NewDownloadManager() *browser.DownloadManager {
+	return browser.NewDownloadManager(downloadWorkers)
+}
+
+// EnableMod downloads the mod's files with dm, which may be nil, and installs them. downloads, if set, is passed the
+// progress of each download as well.
+func EnableMod(ctx context.Context, game config.Game, modID string, toInstall []*mods.DownloadFiles, dm *browser.DownloadManager, progress Progress, downloads func(browser.DownloadProgress)) (err error) {
+	var (
+		tm        *model.TrackedMod
+		dlf       map[*mods.Download]*mods.DownloadFiles
+		dls       []*mods.Download
+		items     []*browser.DownloadItem
+		installed []string
+		toMove    []downloadedFiles
+		tx        *io.Transaction
+		dir       string
+	)
+	if tm, err = startEnabling(game, modID); err != nil {
+		return
+	}
+	defer func() {
+		stateMutex.Lock()
+		delete(enabling[game], modID)
+		stateMutex.Unlock()
+	}()
+	if progress == nil {
+		progress = func(string, float64) {}
+	}
+
+	dlf = tm.Mod.CompileDownloadFiles(toInstall)
+	if len(dlf) == 0 {
+		return fmt.Errorf("%s has nothing to install", tm.Mod.Name)
+	}
+	defer func() { _ = os.RemoveAll(path.Join(tm.GetDir(), tempDir)) }()
+
+	for _, dl := range tm.Mod.Downloadables {
+		if _, ok := dlf[dl]; ok {
+			dls = append(dls, dl)
+		}
+	}
+	if dm == nil {
+		dm = NewDownloadManager()
+	}
+	if items, err = download(ctx, dm, tm, dls, progress, downloads); err != nil {
+		return
+	}
+	for i, dl := range dls {
+		f := dlf[dl]
+		dir = path.Join(tm.GetDir(), tempDir, extractDir, strconv.Itoa(i))
+		if err = extract(ctx, dir, dl, items[i], f, progress); err != nil {
+			return
+		}
+		var files []*mods.ModFile
+		if files, err = toModFiles(dir, f); err != nil {
+			return
+		}
+		for _, mf := range files {
+			installed = append(installed, io.ToFile(mf))
+		}
+		toMove = append(toMove, downloadedFiles{dir: dir, files: files})
+	}
+
+	// The state is only locked to check and record the install so the mods can be listed while files are copied
+	installMutex.Lock()
+	defer installMutex.Unlock()
+	stateMutex.Lock()
+	collisions := detectCollisions(getAllFiles(game), installed)
+	stateMutex.Unlock()
+	if len(collisions) > 0 {
+		return fmt.Errorf("cannot enable mod as these files would collide: %s", strings.Join(collisions, ", "))
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	progress("Installing "+tm.Mod.Name, -1)
+	if tx, err = io.BeginInstall(game, modID, installed); err != nil {
+		return
+	}
+	for _, m := range toMove {
+		if err = tx.MoveFiles(m.files, m.dir); err != nil {
+			return rollback(tx, fmt.Errorf("failed to install %s: %v", tm.Mod.Name, err))
+		}
+	}
+	if err = recordInstall(game, tm, tx.Installed()); err != nil {
+		return rollback(tx, err)
+	}
+	return tx.Commit()
+}
+
+// startEnabling marks the mod as being enabled so it is not enabled twice at once
+func startEnabling(game config.Game, modID string) (tm *model.TrackedMod, err error) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	if tm, err = getTrackedMod(game, modID); err != nil {
+		return
+	}
+	if tm.IsEnabled() {
+		return nil, fmt.Errorf("%s is already enabled", tm.Mod.Name)
+	}
+	if enabling[game][modID] {
+		return nil, fmt.Errorf("%s is already being enabled", tm.Mod.Name)
+	}
+	if enabling[game] == nil {
+		enabling[game] = make(map[string]bool)
+	}
+	enabling[game][modID] = true
+	return
+}
+
+// recordInstall saves the mod's installed files and that it is enabled, which commits the install. A journal left
+// after it is only removed by RecoverInstall.
+func recordInstall(game config.Game, tm *model.TrackedMod, files []*io.InstalledFile) (err error) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	if err = addModFiles(game, tm.GetModID(), files); err != nil {
+		return
+	}
+	tm.SetIsEnabled(true)
+	if err = saveState(); err != nil {
+		tm.SetIsEnabled(false)
+		forgetModFiles(game, tm.GetModID())
+	}
+	return
+}
+
+func rollback(tx *io.Transaction, err error) error {
+	if rbErr := tx.Rollback(); rbErr != nil {
+		return fmt.Errorf("%v\n%v", err, rbErr)
+	}
+	return err
+}
+
+func DisableMod(game config.Game, modID string) (err error) {
+	var tm *model.TrackedMod
+	installMutex.Lock()
+	defer installMutex.Unlock()
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	if tm, err = getTrackedMod(game, modID); err != nil {
+		return
+	}
+	if !tm.IsEnabled() {
+		return nil
+	}
+	if err = removeModFiles(game, modID); err != nil {
+		// The files that were reverted are saved as such so trying again does not revert them twice
+		_ = saveState()
+		return
+	}
+	tm.SetIsEnabled(false)
+	return saveState()
+}
+
+// download fetches every download the mod's files come from, concurrently
+func download(ctx context.Context, dm *browser.DownloadManager, tm *model.TrackedMod, dls []*mods.Download, progress Progress, downloads func(browser.DownloadProgress)) (items []*browser.DownloadItem, err error) {
+	items = make([]*browser.DownloadItem, len(dls))
+	for i, dl := range dls {
+		if len(dl.Sources) == 0 {
+			return nil, fmt.Errorf("%s has no sources", dl.Name)
+		}
+		sha, size := dl.SHA256, dl.Size
+		items[i] = &browser.DownloadItem{
+			Name:    dl.Name,
+			Sources: dl.Sources,
+			Verify: func(file string) error {
+				return browser.VerifyFile(file, sha, size)
+			},
+		}
+	}
+	err = dm.Download(ctx, path.Join(tm.GetDir(), tempDir), items, func(p browser.DownloadProgress) {
+		progress(p.String(), p.Fraction())
+		if downloads != nil {
+			downloads(p)
+		}
+	})
+	return
+}
+
+// extract extracts only the parts of the download that files copy from into dir
+func extract(ctx context.Context, dir string, dl *mods.Download, item *browser.DownloadItem, files *mods.DownloadFiles, progress Progress) (err error) {
+	var d decompressor.Decompressor
+	if d, err = decompressor.NewDecompressor(item.File); err != nil {
+		return
+	}
+	if err = os.RemoveAll(dir); err != nil {
+		return
+	}
+	status := fmt.Sprintf("Extracting %s (from %s)", dl.Name, item.Source)
+	if err = d.DecompressFiles(ctx, dir, files, func(p decompressor.Progress) {
+		if p.TotalBytes > 0 {
+			progress(status, float64(p.Written)/float64(p.TotalBytes))
+		} else if p.TotalEntries > 0 {
+			progress(status, float64(p.Entries)/float64(p.TotalEntries))
+		} else {
+			progress(status, -1)
+		}
+	}); err != nil && ctx.Err() == nil {
+		err = fmt.Errorf("failed to decompress %s: %v", dl.Name, err)
+	}
+	return
+}
+
+func toModFiles(dir string, dlf *mods.DownloadFiles) (files []*mods.ModFile, err error) {
+	var dirFiles []*mods.ModFile
+	if dirFiles, err = io.ExpandDirs(dlf.Dirs, dir); err != nil {
+		return
+	}
+	files = append(files, dlf.Files...)
+	files = append(files, dirFiles...)
+	return
+}
+
+func getTrackedMod(game config.Game, modID string) (*model.TrackedMod, error) {
+	for _, tm := range lookup[game].Mods {
+		if tm.GetModID() == modID {
+			return tm, nil
+		}
+	}
+	return nil, errors.New("failed to find " + modID)
+}
@@ -9,17 +9,18 @@ import (
 	"github.com/kiamev/moogle-mod-manager/config"
 	"github.com/kiamev/moogle-mod-manager/mods"
 	"github.com/kiamev/moogle-mod-manager/mods/managed/model"
+	"github.com/kiamev/moogle-mod-manager/nexus"
+	"github.com/kiamev/moogle-mod-manager/persist"
 	"io/ioutil"
 	"os"
 	"path"
+	"sync"
 )
 
 const (
 	moogleModName = "mod.moogle"
 
 	tempDir = "temp"
-
-	modTrackerName = "tracker.json"
 )
 
 type trackedModsForGame struct {
@@ -27,28 +28,27 @@ type trackedModsForGame struct {
 	Mods []*model.TrackedMod `json:"mods"`
 }
 
-// lookup first slice is the game, second slice is the mod
-var lookup = make([]*trackedModsForGame, 6)
+var (
+	// lookup first slice is the game, second slice is the mod
+	lookup = make([]*trackedModsForGame, len(config.Games()))
+	// stateMutex is held while lookup and managed are read or changed and while they are saved, as installs run in
+	// the background and links from other instances are added at any time. Only the exported functions lock it.
+	stateMutex sync.Mutex
+	// installMutex is held while files are installed or reverted, as a game has one install journal. It is locked
+	// before stateMutex, which an install only holds to check and record what it installs.
+	installMutex sync.Mutex
+	// enabling are the mods being enabled, which share their temp dir, by game then mod ID
+	enabling = make(map[config.Game]map[string]bool)
+)
 
 func Initialize() (err error) {
-	var (
-		f = path.Join(config.PWD, modTrackerName)
-		b []byte
-	)
-	if _, err = os.Stat(f); err != nil {
-		// ignore, probably first run
-		for i := range lookup {
-			lookup[i] = &trackedModsForGame{Game: config.Game(i)}
-		}
-		return saveToJson()
-	} else {
-		if b, err = ioutil.ReadFile(f); err != nil {
-			return
-		}
-		if err = json.Unmarshal(b, &lookup); err != nil {
-			return
-		}
+	var b []byte
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	if err = loadState(); err != nil {
+		return
 	}
+	// The definitions are loaded before anything else can fail, the mods are listed by them
 	for _, tms := range lookup {
 		for _, tm := range tms.Mods {
 			if b, err = readFile(path.Join(tm.Dir, moogleModName)); err != nil {
@@ -61,7 +61,11 @@ func Initialize() (err error) {
 			tm.Mod = mod
 		}
 	}
-	return nil
+	for i := range lookup {
+		recoverInstall(config.Game(i))
+	}
+	issues, err = reconcile()
+	return
 }
 
 func AddModFromFile(game config.Game, file string) (err error) {
@@ -90,33 +94,55 @@ func AddModFromFile(game config.Game, file string) (err error) {
 	return AddMod(game, model.NewTrackerMod(game, mod))
 }
 
-func AddModFromUrl(game config.Game, url string) error {
-	b, err := browser.DownloadAsBytes(url)
+func AddModFromUrl(game config.Game, url string) (*model.TrackedMod, error) {
+	if nexus.IsLink(url) {
+		return addModFromNexus(game, url)
+	}
+	mod, err := modFromUrl(url)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	tm := model.NewTrackerMod(game, mod)
+	return tm, AddMod(game, tm)
+}
+
+func modFromUrl(url string) (mod *mods.Mod, err error) {
+	var b []byte
+	if b, err = browser.DownloadAsBytes(url); err != nil {
+		return
 	}
-	var mod *mods.Mod
-	if b[0] == '<' {
+	if len(b) > 0 && b[0] == '<' {
 		err = xml.Unmarshal(b, &mod)
 	} else {
 		err = json.Unmarshal(b, &mod)
 	}
 	if err != nil {
-		return fmt.Errorf("failed to load mod: %v", err)
+		return nil, fmt.Errorf("failed to load mod: %v", err)
 	}
-	return AddMod(game, model.NewTrackerMod(game, mod))
+	if s := mod.Validate(); s != "" {
+		return nil, fmt.Errorf("failed to load mod:\n%s", s)
+	}
+	return
 }
 
 func AddMod(game config.Game, tm *model.TrackedMod) (err error) {
+	if !mods.IsName(tm.Mod.ID) {
+		return fmt.Errorf("%s is not a valid mod ID", tm.Mod.ID)
+	}
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
 	if err = tm.GetMod().Supports(game); err != nil {
 		return
 	}
 
 	tm.Enabled = false
 	for _, g := range tm.Mod.Games {
-		i := int(config.NameToGame(g.Name))
-		m := lookup[i]
-		for i = range m.Mods {
+		gm, ok := config.NameToGame(g.Name)
+		if !ok {
+			continue
+		}
+		m := lookup[gm]
+		for i := range m.Mods {
 			if m.Mods[i].Mod.ID == tm.Mod.ID {
 				return errors.New("mod already added")
 			}
@@ -124,10 +150,7 @@ func AddMod(game config.Game, tm *model.TrackedMod) (err error) {
 		m.Mods = append(m.Mods, tm)
 	}
 
-	var (
-		b []byte
-		f *os.File
-	)
+	var b []byte
 	if b, err = json.MarshalIndent(tm.Mod, "", "\t"); err != nil {
 		return
 	}
@@ -136,34 +159,43 @@ func AddMod(game config.Game, tm *model.TrackedMod) (err error) {
 			return
 		}
 	}
-	if f, err = os.Create(path.Join(tm.GetDir(), moogleModName)); err != nil {
-		return
-	}
-	defer func() { _ = f.Close() }()
-	if _, err = f.Write(b); err != nil {
+	if err = persist.WriteFile(path.Join(tm.GetDir(), moogleModName), b); err != nil {
 		return
 	}
 
-	return saveToJson()
+	return saveState()
 }
 
-func GetMods(game config.Game) []*model.TrackedMod { return lookup[game].Mods }
+// GetMods is a copy of the game's mods, which stays the same when mods are added or removed
+func GetMods(game config.Game) []*model.TrackedMod {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	return append([]*model.TrackedMod(nil), lookup[game].Mods...)
+}
 
 func RemoveMod(game config.Game, modID string) error {
+	installMutex.Lock()
+	defer installMutex.Unlock()
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
 	gm := lookup[game].Mods
 	for i, m := range gm {
 		if m.Mod.ID != modID {
-			return fmt.Errorf("failed to find %s", modID)
+			continue
+		}
+		if enabling[game][modID] {
+			return fmt.Errorf("%s is being enabled", m.Mod.Name)
 		}
 		if m.Enabled {
-			if err := RemoveModFiles(game, modID); err != nil {
-
+			if err := removeModFiles(game, modID); err != nil {
+				_ = saveState()
+				return err
 			}
 		}
 		lookup[game].Mods = append(gm[:i], gm[i+1:]...)
-		break
+		return saveState()
 	}
-	return saveToJson()
+	return fmt.Errorf("failed to find %s", modID)
 }
 
 /*
@@ -208,11 +240,3 @@ func readFile(f string) (b []byte, err error) {
 	}
 	return
 }
-
-func saveToJson() error {
-	b, err := json.MarshalIndent(&lookup, "", "\t")
-	if err != nil {
-		return err
-	}
-	return ioutil.WriteFile(path.Join(config.PWD, modTrackerName), b, 0755)
-}
@@ -0,0 +1,63 @@
+package managed
+
+import (
+	"context"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/mods"
+	"github.com/kiamev/moogle-mod-manager/mods/managed/model"
+	"github.com/kiamev/moogle-mod-manager/nexus"
+)
+
+// addModFromNexus adds the mod a Nexus mod page or nxm link is to. The moogle definition the mod's description links
+// to is used when there is one, otherwise the mod is made from its Nexus metadata. An nxm link adds only its file.
+func addModFromNexus(game config.Game, link string) (tm *model.TrackedMod, err error) {
+	var (
+		ctx   = context.Background()
+		l     *nexus.Link
+		info  *nexus.ModInfo
+		files []*nexus.File
+		mod   *mods.Mod
+		g     config.Game
+	)
+	if l, err = nexus.ParseLink(link); err != nil {
+		return
+	}
+	if g, err = l.ToGame(); err != nil {
+		return
+	} else if g != game {
+		return nil, fmt.Errorf("%s is a mod for %s, not %s", link, config.GameNameString(g), config.GameNameString(game))
+	}
+	l.RememberKey()
+	if info, err = nexus.GetMod(ctx, l); err != nil {
+		return
+	}
+	for _, dl := range nexus.DefinitionLinks(info) {
+		if m, mErr := modFromUrl(dl); mErr == nil && m.Supports(game) == nil {
+			mod = m
+			break
+		}
+	}
+	if mod == nil {
+		if files, err = nexus.GetFiles(ctx, l); err != nil {
+			return
+		}
+		if l.FileID != 0 {
+			files = onlyFile(files, l.FileID)
+		}
+		if mod, err = nexus.NewMod(l, info, files); err != nil {
+			return
+		}
+	}
+	tm = model.NewTrackerMod(game, mod)
+	return tm, AddMod(game, tm)
+}
+
+func onlyFile(files []*nexus.File, fileID int) []*nexus.File {
+	for _, f := range files {
+		if f.FileID == fileID {
+			return []*nexus.File{f}
+		}
+	}
+	return files
+}
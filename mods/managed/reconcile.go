@@ -0,0 +1,241 @@
+package managed
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/mods/io"
+	"os"
+	"path"
+)
+
+type Problem string
+
+const (
+	// MissingFile is an installed file that is no longer in the game's directory
+	MissingFile Problem = "is missing from the game"
+	// MissingBackup is an installed file whose backup of the game file it replaced is gone
+	MissingBackup Problem = "has lost the backup of the game file it replaced"
+	// OrphanedBackup is a backup of a game file that no installed file replaced
+	OrphanedBackup Problem = "is a backup that no mod's file replaced"
+	// NoFiles is a mod that is enabled without any installed files
+	NoFiles Problem = "is enabled but none of its files are installed"
+	// NotEnabled is a mod that has installed files but is not enabled
+	NotEnabled Problem = "has installed files but is not enabled"
+	// Unrecovered is an install that was interrupted and could not be rolled back on start
+	Unrecovered Problem = "was interrupted and could not be rolled back"
+)
+
+// Issue is a place where the mod manager's state and the game's files disagree
+type Issue struct {
+	Game    config.Game
+	ModID   string
+	File    string
+	Problem Problem
+	// Detail is why an install was Unrecovered
+	Detail string
+}
+
+var (
+	issues []*Issue
+	// unrecovered are why the games' interrupted installs could not be rolled back
+	unrecovered = make(map[config.Game]string)
+)
+
+// Issues are what Initialize or the last Repair found wrong with the installed mods
+func Issues() []*Issue {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	return issues
+}
+
+func (i *Issue) String() string {
+	switch i.Problem {
+	case OrphanedBackup:
+		return fmt.Sprintf("%s: %s %s", config.GameNameString(i.Game), i.File, i.Problem)
+	case Unrecovered:
+		return fmt.Sprintf("%s: an install %s: %s", config.GameNameString(i.Game), i.Problem, i.Detail)
+	case NoFiles, NotEnabled:
+		return fmt.Sprintf("%s: %s %s", config.GameNameString(i.Game), i.ModID, i.Problem)
+	}
+	return fmt.Sprintf("%s: %s's file %s %s", config.GameNameString(i.Game), i.ModID, i.File, i.Problem)
+}
+
+// Fix is what Repair does about the issue
+func (i *Issue) Fix() string {
+	switch i.Problem {
+	case MissingFile:
+		return "The file is forgotten and the game's original, if it has a backup, is restored"
+	case MissingBackup:
+		return "The file is forgotten so it is left in the game when the mod is disabled"
+	case OrphanedBackup:
+		return "The backup is restored to the game"
+	case NoFiles:
+		return "The mod is disabled so it can be enabled again"
+	case NotEnabled:
+		return "The mod's files are removed from the game"
+	case Unrecovered:
+		return "The install is rolled back again. A journal that cannot be read is set aside, the backups it made are then listed to restore"
+	}
+	return ""
+}
+
+// reconcile compares the installed files of every game with the game's directory and backup dir
+func reconcile() (found []*Issue, err error) {
+	for _, tms := range lookup {
+		game := tms.Game
+		if detail, ok := unrecovered[game]; ok {
+			found = append(found, &Issue{Game: game, Problem: Unrecovered, Detail: detail})
+		}
+		var (
+			gameDir   = config.GetModDir(game)
+			backupDir = config.GetBackupDir(game)
+			installed = make(map[string]bool)
+			replaced  = make(map[string]bool)
+			backups   []string
+		)
+		if m, ok := managed[game]; ok {
+			for _, mf := range m.Mods {
+				installed[mf.ModID] = true
+				if tm, _ := getTrackedMod(game, mf.ModID); tm == nil || !tm.Enabled {
+					found = append(found, &Issue{Game: game, ModID: mf.ModID, Problem: NotEnabled})
+				}
+				for _, f := range mf.Files {
+					if !exists(path.Join(gameDir, f.File)) {
+						found = append(found, &Issue{Game: game, ModID: mf.ModID, File: f.File, Problem: MissingFile})
+					}
+					if f.Replaced {
+						replaced[f.File] = true
+						if !exists(path.Join(backupDir, f.File)) {
+							found = append(found, &Issue{Game: game, ModID: mf.ModID, File: f.File, Problem: MissingBackup})
+						}
+					}
+				}
+			}
+		}
+		for _, tm := range tms.Mods {
+			if tm.Enabled && !installed[tm.Mod.ID] {
+				found = append(found, &Issue{Game: game, ModID: tm.Mod.ID, Problem: NoFiles})
+			}
+		}
+		if backups, err = io.BackupFiles(game); err != nil {
+			return nil, fmt.Errorf("failed to read the backups of %s: %v", config.GameNameString(game), err)
+		}
+		for _, b := range backups {
+			if !replaced[b] {
+				found = append(found, &Issue{Game: game, File: b, Problem: OrphanedBackup})
+			}
+		}
+	}
+	return
+}
+
+// Repair fixes the issues as their Fix describes, then looks for issues again
+func Repair(toFix []*Issue) (err error) {
+	installMutex.Lock()
+	defer installMutex.Unlock()
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	for _, i := range toFix {
+		if err = repair(i); err != nil {
+			err = fmt.Errorf("failed to repair %s: %v", i, err)
+			break
+		}
+	}
+	if sErr := saveState(); err == nil {
+		err = sErr
+	}
+	if found, rErr := reconcile(); rErr == nil {
+		issues = found
+	} else if err == nil {
+		err = rErr
+	}
+	return
+}
+
+func repair(i *Issue) error {
+	switch i.Problem {
+	case MissingFile, MissingBackup:
+		if f := forgetFile(i.Game, i.ModID, i.File); f != nil && i.Problem == MissingFile {
+			return restoreBackup(i.Game, f.File)
+		}
+	case OrphanedBackup:
+		return restoreBackup(i.Game, i.File)
+	case Unrecovered:
+		if err := io.DiscardDamagedJournal(i.Game); err != nil {
+			return err
+		}
+		if !recoverInstall(i.Game) {
+			return errors.New(unrecovered[i.Game])
+		}
+	case NoFiles:
+		if tm, _ := getTrackedMod(i.Game, i.ModID); tm != nil {
+			tm.SetIsEnabled(false)
+		}
+	case NotEnabled:
+		if m, ok := managed[i.Game]; ok {
+			for _, mf := range m.Mods {
+				if mf.ModID != i.ModID {
+					continue
+				}
+				for _, f := range mf.Files {
+					if err := removeFile(i.Game, f); err != nil {
+						return err
+					}
+				}
+				forgetModFiles(i.Game, i.ModID)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// recoverInstall rolls back the game's interrupted install, if it has one, keeping why it could not be for reconcile
+func recoverInstall(game config.Game) bool {
+	if err := io.RecoverInstall(game, func(modID string) bool { return hasModFiles(game, modID) }); err != nil {
+		unrecovered[game] = err.Error()
+		return false
+	}
+	delete(unrecovered, game)
+	return true
+}
+
+// forgetFile drops the file from the mod's installed files, returning it if it was there
+func forgetFile(game config.Game, modID string, file string) *io.InstalledFile {
+	m, ok := managed[game]
+	if !ok {
+		return nil
+	}
+	for i, mf := range m.Mods {
+		if mf.ModID != modID {
+			continue
+		}
+		for j, f := range mf.Files {
+			if f.File == file {
+				m.Mods[i].Files = append(mf.Files[:j], mf.Files[j+1:]...)
+				delete(m.AllFiles, file)
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// removeFile takes the installed file out of the game, restoring the game's original when it has a backup. A file that
+// replaced a game file whose backup is gone is left in place, as it is the only copy of the game file there is.
+func removeFile(game config.Game, f *io.InstalledFile) error {
+	return io.RevertMoveFiles([]*io.InstalledFile{f}, game, nil)
+}
+
+func restoreBackup(game config.Game, file string) error {
+	if !exists(path.Join(config.GetBackupDir(game), file)) {
+		return nil
+	}
+	return io.RevertMoveFiles([]*io.InstalledFile{{File: file, Replaced: true}}, game, nil)
+}
+
+func exists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
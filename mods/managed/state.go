@@ -0,0 +1,246 @@
+package managed
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/mods/io"
+	"github.com/kiamev/moogle-mod-manager/mods/managed/model"
+	"github.com/kiamev/moogle-mod-manager/persist"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+const (
+	stateName = "state.json"
+	// stateVersion is the version of the state file's schema, each change to it comes with a migration
+	stateVersion = 2
+
+	// legacyTrackerName and legacyManagedName are the files the state was kept in before the state file
+	legacyTrackerName = "tracker.json"
+	legacyManagedName = "managed.json"
+	migratedExt       = ".migrated"
+)
+
+// state is everything the manager keeps track of. It is saved as a single file so the mods' enabled state and the
+// files they installed are always written together.
+type state struct {
+	Version int          `json:"version"`
+	Games   []*gameState `json:"games"`
+}
+
+type gameState struct {
+	Game gameID              `json:"game"`
+	Mods []*model.TrackedMod `json:"mods"`
+	// Installed are the files of each enabled mod
+	Installed []modFiles `json:"installed,omitempty"`
+}
+
+// gameID is a game's id in games.json, which a game's state is saved with so it does not depend on the order the games
+// are defined in
+type gameID string
+
+// UnmarshalJSON reads the game's id, or the game's position in games.json that version 1 saved
+func (id *gameID) UnmarshalJSON(b []byte) error {
+	var game config.Game
+	if err := json.Unmarshal(b, &game); err == nil {
+		*id = gameID(config.String(game))
+		return nil
+	}
+	return json.Unmarshal(b, (*string)(id))
+}
+
+// migrations upgrade the state from the version at their index to the next one. Version 0 is the tracker.json and
+// managed.json files that came before the state file.
+var migrations = []func(s *state) error{
+	migrateLegacyFiles,
+	// Version 2 saves the games by their id, gameID reads version 1's positions as ids
+	func(*state) error { return nil },
+}
+
+// unknownGames are the games in the state that are not defined in games.json, which are saved as they were loaded
+var unknownGames []*gameState
+
+// loadErr is why the state could not be loaded. Saving is refused until it is resolved, as saving what was loaded
+// would replace the state and its backups with an empty or partial one.
+var loadErr error
+
+func stateFile() string {
+	return path.Join(config.PWD, stateName)
+}
+
+// loadState reads the state file into lookup and managed, migrating it from older versions first
+func loadState() (err error) {
+	s := &state{}
+	loadErr = nil
+	defer func() { loadErr = err }()
+	// The lookup is filled first so the mods are listed, empty, even when the state cannot be read
+	for i := range lookup {
+		lookup[i] = &trackedModsForGame{Game: config.Game(i)}
+	}
+	managed = make(map[config.Game]*managedModsAndFiles)
+	unknownGames = nil
+	if err = persist.LoadJSON(stateFile(), s); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %v", stateName, err)
+	}
+	if s.Version > stateVersion {
+		return fmt.Errorf("%s was saved by a newer version of the mod manager", stateName)
+	}
+	migrated := s.Version < stateVersion
+	for ; s.Version < stateVersion; s.Version++ {
+		if err = migrations[s.Version](s); err != nil {
+			return fmt.Errorf("failed to update %s: %v", stateName, err)
+		}
+	}
+
+	for _, gs := range s.Games {
+		game, ok := config.FromString(string(gs.Game))
+		if !ok {
+			if gs.Game != "" {
+				unknownGames = append(unknownGames, gs)
+			}
+			continue
+		}
+		lookup[game].Mods = gs.Mods
+		m := &managedModsAndFiles{Mods: gs.Installed, AllFiles: make(map[string]bool)}
+		for _, mf := range m.Mods {
+			for _, f := range mf.Files {
+				m.AllFiles[f.File] = true
+			}
+		}
+		managed[game] = m
+	}
+
+	if migrated {
+		if err = saveState(); err != nil {
+			return
+		}
+		retireLegacyFiles()
+	}
+	return nil
+}
+
+// saveState writes lookup and managed to the state file, its caller holds stateMutex
+func saveState() error {
+	if loadErr != nil {
+		return fmt.Errorf("changes are not saved until %s is fixed or removed and the mod manager restarted: %v", stateName, loadErr)
+	}
+	s := &state{Version: stateVersion}
+	for _, tms := range lookup {
+		gs := &gameState{Game: gameID(config.String(tms.Game)), Mods: tms.Mods}
+		if m, ok := managed[tms.Game]; ok {
+			gs.Installed = m.Mods
+		}
+		s.Games = append(s.Games, gs)
+	}
+	s.Games = append(s.Games, unknownGames...)
+	return persist.SaveJSON(stateFile(), s)
+}
+
+// legacyManaged is a game's enabled mods in managed.json
+type legacyManaged struct {
+	Mods []struct {
+		ModID string
+		// Files were only the installed files' paths before their hashes were kept
+		Files []json.RawMessage
+	}
+}
+
+// migrateLegacyFiles reads tracker.json, which held each game's mods and whether they are enabled, and managed.json,
+// which held the files of the enabled mods
+func migrateLegacyFiles(s *state) (err error) {
+	var (
+		tracked []*trackedModsForGame
+		files   map[config.Game]*legacyManaged
+		games   = make(map[config.Game]*gameState)
+	)
+	if err = readLegacyFile(legacyTrackerName, &tracked); err != nil {
+		return
+	}
+	if err = readLegacyFile(legacyManagedName, &files); err != nil {
+		return
+	}
+	s.Games = nil
+	get := func(game config.Game) *gameState {
+		gs, ok := games[game]
+		if !ok {
+			gs = &gameState{Game: gameID(config.String(game))}
+			games[game] = gs
+			s.Games = append(s.Games, gs)
+		}
+		return gs
+	}
+	for _, tms := range tracked {
+		if tms != nil {
+			get(tms.Game).Mods = tms.Mods
+		}
+	}
+	for game, m := range files {
+		if m == nil {
+			continue
+		}
+		gs := get(game)
+		for _, lmf := range m.Mods {
+			mf := modFiles{ModID: lmf.ModID}
+			for _, raw := range lmf.Files {
+				var f *io.InstalledFile
+				if f, err = legacyInstalledFile(game, raw); err != nil {
+					return fmt.Errorf("failed to read %s: %v", legacyManagedName, err)
+				}
+				mf.Files = append(mf.Files, f)
+			}
+			gs.Installed = append(gs.Installed, mf)
+		}
+	}
+	return nil
+}
+
+// legacyInstalledFile reads a file of managed.json. A file that is only a path replaced a game file when the game
+// file's backup is there.
+func legacyInstalledFile(game config.Game, raw json.RawMessage) (f *io.InstalledFile, err error) {
+	var file string
+	if err = json.Unmarshal(raw, &file); err != nil {
+		err = json.Unmarshal(raw, &f)
+		return
+	}
+	return &io.InstalledFile{File: file, Replaced: exists(path.Join(config.GetBackupDir(game), file))}, nil
+}
+
+// legacyFiles are where the file the state was kept in may be. managed.json was written to the working dir, which is
+// not always the dir the manager runs from.
+func legacyFiles(name string) (files []string) {
+	files = append(files, path.Join(config.PWD, name))
+	if wd, err := os.Getwd(); err == nil && path.Clean(wd) != path.Clean(config.PWD) {
+		files = append(files, path.Join(wd, name))
+	}
+	return
+}
+
+func readLegacyFile(name string, to interface{}) error {
+	for _, f := range legacyFiles(name) {
+		b, err := ioutil.ReadFile(f)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		if err = json.Unmarshal(b, to); err != nil {
+			return fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// retireLegacyFiles renames the files the state was migrated from so they are not mistaken for the current state
+func retireLegacyFiles() {
+	for _, name := range []string{legacyTrackerName, legacyManagedName} {
+		for _, f := range legacyFiles(name) {
+			if _, err := os.Stat(f); err == nil {
+				_ = os.Rename(f, f+migratedExt)
+			}
+		}
+	}
+}
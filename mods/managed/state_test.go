@@ -0,0 +1,210 @@
+package managed
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/mods/io"
+	"github.com/kiamev/moogle-mod-manager/persist"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testTrackerJSON = `[{"game": 0, "mods": [{"Enabled": true, "Dir": "mods/a"}, {"Enabled": false, "Dir": "mods/b"}]}]`
+	// testManagedJSON has files that are only paths, as they were first saved, and files with their hashes
+	testManagedJSON = `{"0": {"Mods": [
+		{"ModID": "a", "Files": ["replaced.txt", "added.txt", {"File": "hashed.txt", "Replaced": true, "Hash": "abc", "BackupHash": "def"}]}
+	]}}`
+)
+
+func writeFile(t *testing.T, f string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(path.Dir(f), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chdir makes dir the working dir until the test ends
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func TestMigrateLegacyFiles(t *testing.T) {
+	game := config.Game(0)
+	for _, tt := range []struct {
+		name string
+		// inWorkingDir is whether managed.json is in the working dir rather than the dir the manager runs from
+		inWorkingDir bool
+	}{
+		{name: "in the manager's dir"},
+		{name: "in the working dir", inWorkingDir: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config.PWD = t.TempDir()
+			wd := t.TempDir()
+			chdir(t, wd)
+			managedDir := config.PWD
+			if tt.inWorkingDir {
+				managedDir = wd
+			}
+			writeFile(t, path.Join(config.PWD, legacyTrackerName), testTrackerJSON)
+			writeFile(t, path.Join(managedDir, legacyManagedName), testManagedJSON)
+			writeFile(t, path.Join(config.GetBackupDir(game), "replaced.txt"), "game file")
+
+			if err := loadState(); err != nil {
+				t.Fatal(err)
+			}
+			var enabled []bool
+			for _, tm := range lookup[game].Mods {
+				enabled = append(enabled, tm.Enabled)
+			}
+			if !reflect.DeepEqual(enabled, []bool{true, false}) {
+				t.Errorf("the migrated mods are enabled %v, want [true false]", enabled)
+			}
+			want := []modFiles{{ModID: "a", Files: []*io.InstalledFile{
+				{File: "replaced.txt", Replaced: true},
+				{File: "added.txt"},
+				{File: "hashed.txt", Replaced: true, Hash: "abc", BackupHash: "def"},
+			}}}
+			if got := managed[game].Mods; !reflect.DeepEqual(got, want) {
+				t.Errorf("the migrated files are %+v, want %+v", got, want)
+			}
+			if !managed[game].AllFiles["added.txt"] {
+				t.Error("the migrated files are not all tracked")
+			}
+
+			if _, err := os.Stat(stateFile()); err != nil {
+				t.Errorf("the migrated state was not saved: %v", err)
+			}
+			for _, f := range []string{path.Join(config.PWD, legacyTrackerName), path.Join(managedDir, legacyManagedName)} {
+				if _, err := os.Stat(f); !os.IsNotExist(err) {
+					t.Errorf("%s was not retired", f)
+				}
+				if _, err := os.Stat(f + migratedExt); err != nil {
+					t.Error(err)
+				}
+			}
+
+			// The saved state loads the same without the legacy files
+			if err := loadState(); err != nil {
+				t.Fatal(err)
+			}
+			if got := managed[game].Mods; !reflect.DeepEqual(got, want) {
+				t.Errorf("the reloaded files are %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLegacyInstalledFile(t *testing.T) {
+	config.PWD = t.TempDir()
+	game := config.Game(0)
+	writeFile(t, path.Join(config.GetBackupDir(game), "dir", "replaced.txt"), "game file")
+	for _, tt := range []struct {
+		raw  string
+		want io.InstalledFile
+	}{
+		{raw: `"dir/replaced.txt"`, want: io.InstalledFile{File: "dir/replaced.txt", Replaced: true}},
+		{raw: `"dir/added.txt"`, want: io.InstalledFile{File: "dir/added.txt"}},
+		{raw: `{"File": "dir/added.txt", "Hash": "abc"}`, want: io.InstalledFile{File: "dir/added.txt", Hash: "abc"}},
+	} {
+		f, err := legacyInstalledFile(game, []byte(tt.raw))
+		if err != nil {
+			t.Errorf("legacyInstalledFile(%s): %v", tt.raw, err)
+		} else if !reflect.DeepEqual(*f, tt.want) {
+			t.Errorf("legacyInstalledFile(%s) = %+v, want %+v", tt.raw, *f, tt.want)
+		}
+	}
+	if _, err := legacyInstalledFile(game, []byte(`42`)); err == nil {
+		t.Error("legacyInstalledFile of a number succeeded")
+	}
+}
+
+func TestLoadDamagedState(t *testing.T) {
+	config.PWD = t.TempDir()
+	chdir(t, t.TempDir())
+	writeFile(t, stateFile(), "{")
+	writeFile(t, stateFile()+".1", "[")
+	if err := loadState(); err == nil {
+		t.Fatal("loading a damaged state succeeded")
+	}
+	if err := saveState(); err == nil {
+		t.Error("the damaged state was replaced")
+	}
+	if b, _ := ioutil.ReadFile(stateFile()); string(b) != "{" {
+		t.Errorf("the damaged state is now %s", b)
+	}
+
+	// A backup that can be read is loaded instead
+	writeFile(t, stateFile()+".1", `{"version": 2, "games": [{"game": "I", "mods": [{"Enabled": false, "Dir": "mods/a"}]}]}`)
+	if err := loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lookup[0].Mods) != 1 {
+		t.Errorf("the backup's mods were not loaded: %v", lookup[0].Mods)
+	}
+	if err := saveState(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadStateByGameID(t *testing.T) {
+	config.PWD = t.TempDir()
+	chdir(t, t.TempDir())
+	vi, _ := config.FromString("VI")
+	// Version 1 saved the games by their position in games.json
+	writeFile(t, stateFile(), fmt.Sprintf(`{"version": 1, "games": [{"game": %d, "mods": [{"Enabled": false, "Dir": "mods/a"}]}]}`, vi))
+	if err := loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lookup[vi].Mods) != 1 {
+		t.Fatalf("the version 1 state's mods are not VI's: %v", lookup[vi].Mods)
+	}
+	var s struct {
+		Version int
+		Games   []struct {
+			Game string
+			Mods []json.RawMessage
+		}
+	}
+	if err := persist.LoadJSON(stateFile(), &s); err != nil {
+		t.Fatal(err)
+	}
+	var saved []string
+	for _, gs := range s.Games {
+		if len(gs.Mods) > 0 {
+			saved = append(saved, gs.Game)
+		}
+	}
+	if s.Version != stateVersion || !reflect.DeepEqual(saved, []string{"VI"}) {
+		t.Errorf("the state was saved as version %d with mods for %v, want version %d with mods for [VI]", s.Version, saved, stateVersion)
+	}
+
+	// Games that are not defined are kept as they are
+	writeFile(t, stateFile(), `{"version": 2, "games": [{"game": "VI", "mods": []}, {"game": "XVI", "mods": [{"Enabled": true, "Dir": "mods/b"}]}]}`)
+	if err := loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveState(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(stateFile()); !strings.Contains(string(b), `"XVI"`) || !strings.Contains(string(b), "mods/b") {
+		t.Errorf("the game that is not defined was not saved:\n%s", b)
+	}
+}
@@ -1,12 +1,15 @@
 package mods
 
 import (
+	"encoding/hex"
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
+	"github.com/kiamev/moogle-mod-manager/browser"
 	"github.com/kiamev/moogle-mod-manager/config"
 	"github.com/kiamev/moogle-mod-manager/ui/state"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -70,7 +73,10 @@ func (p *Preview) Get() *canvas.Image {
 			}
 		}
 		if r == nil && p.Url != nil {
-			r, err = fyne.LoadResourceFromURLString(*p.Url)
+			var b []byte
+			if b, err = browser.DownloadAsBytes(*p.Url); err == nil {
+				r = fyne.NewStaticResource(path.Base(*p.Url), b)
+			}
 		}
 		if r == nil || err != nil {
 			return nil
@@ -135,6 +141,9 @@ type Download struct {
 	Name        string      `json:"Name" xml:"Name"`
 	Sources     []string    `json:"Source" xml:"Sources"`
 	InstallType InstallType `json:"InstallType" xml:"InstallType"`
+	// SHA256 and Size are optional, when set a download that does not match them is rejected
+	SHA256 string `json:"SHA256,omitempty" xml:"SHA256,omitempty"`
+	Size   int64  `json:"Size,omitempty" xml:"Size,omitempty"`
 }
 
 type DownloadFiles struct {
@@ -161,7 +170,7 @@ type ModDir struct {
 type Configuration struct {
 	Name        string    `json:"Name" xml:"Name"`
 	Description string    `json:"Description" xml:"Description"`
-	Preview     *Preview  `json:"Preview,omitempty" xml:"Preview, omitempty"`
+	Preview     *Preview  `json:"Preview,omitempty" xml:"Preview,omitempty"`
 	Root        bool      `json:"Root" xml:"Root"`
 	Choices     []*Choice `json:"Choice" xml:"Choices"`
 }
@@ -183,6 +192,8 @@ func (m Mod) Validate() string {
 	sb := strings.Builder{}
 	if m.ID == "" {
 		sb.WriteString("ID is required\n")
+	} else if !IsName(m.ID) {
+		sb.WriteString("ID cannot contain /, \\ or :\n")
 	}
 	if m.Name == "" {
 		sb.WriteString("Name is required\n")
@@ -231,6 +242,12 @@ func (m Mod) Validate() string {
 		if d.InstallType == "" {
 			sb.WriteString(fmt.Sprintf("Downloadables [%s]'s Install Type is required\n", d.Name))
 		}
+		if _, err := hex.DecodeString(d.SHA256); err != nil || (d.SHA256 != "" && len(d.SHA256) != 64) {
+			sb.WriteString(fmt.Sprintf("Downloadables [%s]'s SHA256 must be 64 hex characters\n", d.Name))
+		}
+		if d.Size < 0 {
+			sb.WriteString(fmt.Sprintf("Downloadables [%s]'s Size cannot be negative\n", d.Name))
+		}
 	}
 
 	if (m.DownloadFiles == nil || m.DownloadFiles.IsEmpty()) && len(m.Configurations) == 0 {
@@ -241,6 +258,7 @@ func (m Mod) Validate() string {
 		if m.DownloadFiles.IsEmpty() {
 			sb.WriteString(fmt.Sprintf("DownloadFiles [%s]' Must have at least one File or Dir specified\n", m.DownloadFiles.DownloadName))
 		}
+		validatePaths(&sb, m.DownloadFiles)
 	}
 
 	roots := 0
@@ -258,6 +276,7 @@ func (m Mod) Validate() string {
 			if ch.Name == "" {
 				sb.WriteString(fmt.Sprintf("Configuration's [%s] Choice's Name is required\n", c.Name))
 			}
+			validatePaths(&sb, ch.DownloadFiles)
 			if ch.NextConfigurationName != nil && *ch.NextConfigurationName == c.Name {
 				sb.WriteString(fmt.Sprintf("Configuration's [%s] Choice's Next Configuration Name must not be the same as the Configuration's Name\n", c.Name))
 			}
@@ -274,10 +293,49 @@ func (m Mod) Validate() string {
 	return sb.String()
 }
 
+// CompileDownloadFiles merges the mod's always-installed files with the selected configuration choices, grouped by
+// the Download each mapping comes from.
+func (m *Mod) CompileDownloadFiles(toInstall []*DownloadFiles) map[*Download]*DownloadFiles {
+	dlf := make(map[*Download]*DownloadFiles)
+	dl := make(map[string]*Download)
+	for _, d := range m.Downloadables {
+		dl[d.Name] = d
+	}
+	addDownloadFiles(m.DownloadFiles, dl, dlf)
+	for _, ti := range toInstall {
+		addDownloadFiles(ti, dl, dlf)
+	}
+	return dlf
+}
+
+func addDownloadFiles(ti *DownloadFiles, dl map[string]*Download, dlf map[*Download]*DownloadFiles) {
+	var (
+		d  *Download
+		f  *DownloadFiles
+		ok bool
+	)
+	if ti == nil {
+		return
+	}
+	if d, ok = dl[ti.DownloadName]; !ok {
+		return
+	}
+	if f, ok = dlf[d]; !ok {
+		f = &DownloadFiles{DownloadName: ti.DownloadName}
+		dlf[d] = f
+	}
+	f.Files = append(f.Files, ti.Files...)
+	f.Dirs = append(f.Dirs, ti.Dirs...)
+}
+
 func (m *Mod) Supports(game config.Game) error {
-	gs := " " + config.String(game)
 	for _, g := range m.Games {
-		if strings.HasSuffix(string(g.Name), gs) {
+		if gm, ok := config.NameToGame(g.Name); ok && gm == game {
+			for _, dl := range m.Downloadables {
+				if dl.InstallType != "" && !config.SupportsInstallType(game, string(dl.InstallType)) {
+					return fmt.Errorf("%s cannot install %s downloads", config.GameNameString(game), dl.InstallType)
+				}
+			}
 			return nil
 		}
 	}
@@ -0,0 +1,39 @@
+package mods
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// IsRelative is whether the path stays inside the dir it is relative to: it is not absolute, has no volume name and
+// does not climb out with "..". Both slashes are separators as definitions are shared between platforms.
+func IsRelative(p string) bool {
+	p = strings.ReplaceAll(p, "\\", "/")
+	if strings.HasPrefix(p, "/") || (len(p) >= 2 && p[1] == ':') {
+		return false
+	}
+	p = path.Clean(p)
+	return p != ".." && !strings.HasPrefix(p, "../")
+}
+
+// IsName is whether s can be used as the name of a single dir, as a mod's ID is
+func IsName(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, "/\\:")
+}
+
+func validatePaths(sb *strings.Builder, dlf *DownloadFiles) {
+	if dlf == nil {
+		return
+	}
+	for _, f := range dlf.Files {
+		if !IsRelative(f.From) || !IsRelative(f.To) {
+			sb.WriteString(fmt.Sprintf("File [%s -> %s] must stay inside the download and the game's directory\n", f.From, f.To))
+		}
+	}
+	for _, d := range dlf.Dirs {
+		if !IsRelative(d.From) || !IsRelative(d.To) {
+			sb.WriteString(fmt.Sprintf("Dir [%s -> %s] must stay inside the download and the game's directory\n", d.From, d.To))
+		}
+	}
+}
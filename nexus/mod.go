@@ -0,0 +1,81 @@
+package nexus
+
+import (
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/mods"
+	"regexp"
+	"time"
+)
+
+var definitionRegex = regexp.MustCompile(`https?://[^\s"'<>\[\]]+\.(json|xml)`)
+
+// DefinitionLinks are the links to moogle mod definitions the mod's description has, if its author published one
+func DefinitionLinks(m *ModInfo) []string {
+	return definitionRegex.FindAllString(m.Description, -1)
+}
+
+// NewMod makes a mod from the Nexus mod's metadata, for mods whose author did not publish a moogle definition. Each
+// file is a download that is copied as is into the game's directory. A mod with more than one file lets the user
+// choose which to install. The mod is for the link's game.
+func NewMod(l *Link, m *ModInfo, files []*File) (*mods.Mod, error) {
+	game, err := l.ToGame()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s has no files to download", m.Name)
+	}
+	mod := &mods.Mod{
+		ID:                  fmt.Sprintf("nexus-%s-%d", l.Game, l.ModID),
+		Name:                m.Name,
+		Author:              m.Author,
+		Version:             m.Version,
+		ReleaseDate:         time.Unix(m.CreatedTime, 0).Format("2006-01-02"),
+		Category:            "Nexus Mods",
+		Description:         m.Summary,
+		Link:                l.PageURL(),
+		Games:               []*mods.Game{{Name: config.GameToName(game)}},
+		ConfigSelectionType: mods.Select,
+	}
+	if mod.Author == "" {
+		mod.Author = m.UploadedBy
+	}
+	if m.PictureURL != "" {
+		u := m.PictureURL
+		mod.Preview = &mods.Preview{Url: &u, Size: mods.Size{X: 300, Y: 300}}
+	}
+	for _, f := range files {
+		mod.Downloadables = append(mod.Downloadables, &mods.Download{
+			Name:        f.Name,
+			Sources:     []string{fileURL(l.Game, l.ModID, f.FileID)},
+			InstallType: mods.Compressed,
+			Size:        f.Size,
+		})
+	}
+	if len(files) == 1 {
+		mod.DownloadFiles = copyAll(files[0])
+		return mod, nil
+	}
+	c := &mods.Configuration{
+		Name:        "Files",
+		Description: "Choose the file to install",
+		Root:        true,
+	}
+	for _, f := range files {
+		c.Choices = append(c.Choices, &mods.Choice{
+			Name:          f.Name,
+			Description:   f.Description,
+			DownloadFiles: copyAll(f),
+		})
+	}
+	mod.Configurations = []*mods.Configuration{c}
+	return mod, nil
+}
+
+func copyAll(f *File) *mods.DownloadFiles {
+	return &mods.DownloadFiles{
+		DownloadName: f.Name,
+		Dirs:         []*mods.ModDir{{From: ".", To: ".", Recursive: true}},
+	}
+}
@@ -0,0 +1,229 @@
+package nexus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/browser"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	Scheme = "nxm"
+	site   = "www.nexusmods.com"
+)
+
+var (
+	apiURL = "https://api.nexusmods.com/v1"
+
+	ErrNoAPIKey = errors.New("a Nexus Mods API key is required, set it in File > Configure")
+
+	// downloadKeys are the keys of nxm links opened from the Nexus website, which let accounts that are not premium
+	// download that one file until the key expires
+	downloadKeys      = make(map[string]downloadKey)
+	downloadKeysMutex sync.Mutex
+)
+
+func init() {
+	browser.RegisterResolver(Scheme, resolve)
+}
+
+// Link is a Nexus mod page url, https://www.nexusmods.com/<game>/mods/<id>, or an nxm link to one of its files,
+// nxm://<game>/mods/<id>/files/<file id>?key=<key>&expires=<unix time>
+type Link struct {
+	Game   string
+	ModID  int
+	FileID int
+	key    downloadKey
+}
+
+type downloadKey struct {
+	Key     string
+	Expires int64
+}
+
+// IsLink is whether the url is one ParseLink accepts
+func IsLink(s string) bool {
+	_, err := ParseLink(s)
+	return err == nil
+}
+
+func ParseLink(s string) (l *Link, err error) {
+	var u *url.URL
+	if u, err = url.Parse(strings.TrimSpace(s)); err != nil {
+		return
+	}
+	sp := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch strings.ToLower(u.Scheme) {
+	case Scheme:
+		// The game is the host of nxm links
+		sp = append([]string{u.Host}, sp...)
+	case "http", "https":
+		if strings.ToLower(u.Host) != site && strings.ToLower(u.Host) != "nexusmods.com" {
+			return nil, fmt.Errorf("%s is not a Nexus Mods link", s)
+		}
+	default:
+		return nil, fmt.Errorf("%s is not a Nexus Mods link", s)
+	}
+	if len(sp) < 3 || sp[1] != "mods" {
+		return nil, fmt.Errorf("%s is not a link to a Nexus mod", s)
+	}
+	l = &Link{Game: strings.ToLower(sp[0])}
+	if l.ModID, err = strconv.Atoi(sp[2]); err != nil {
+		return nil, fmt.Errorf("%s is not a link to a Nexus mod", s)
+	}
+	if len(sp) >= 5 && sp[3] == "files" {
+		if l.FileID, err = strconv.Atoi(sp[4]); err != nil {
+			return nil, fmt.Errorf("%s is not a link to a Nexus file", s)
+		}
+	} else if id := u.Query().Get("file_id"); id != "" {
+		l.FileID, _ = strconv.Atoi(id)
+	}
+	q := u.Query()
+	l.key.Key = q.Get("key")
+	l.key.Expires, _ = strconv.ParseInt(q.Get("expires"), 10, 64)
+	return l, nil
+}
+
+// PageURL is the mod's page on the Nexus website
+func (l *Link) PageURL() string {
+	return fmt.Sprintf("https://%s/%s/mods/%d", site, l.Game, l.ModID)
+}
+
+// fileURL is the nxm link a synthetic mod downloads a file from
+func fileURL(game string, modID int, fileID int) string {
+	return fmt.Sprintf("%s://%s/mods/%d/files/%d", Scheme, game, modID, fileID)
+}
+
+// ToGame is the supported game the link's Nexus game is
+func (l *Link) ToGame() (config.Game, error) {
+	if game, ok := config.FromNexusDomain(l.Game); ok {
+		return game, nil
+	}
+	return 0, fmt.Errorf("%s is not a game the mod manager supports", l.Game)
+}
+
+// RememberKey keeps the link's download key, if it has one, for when its file is downloaded
+func (l *Link) RememberKey() {
+	if l.FileID == 0 || l.key.Key == "" {
+		return
+	}
+	downloadKeysMutex.Lock()
+	defer downloadKeysMutex.Unlock()
+	downloadKeys[fileURL(l.Game, l.ModID, l.FileID)] = l.key
+}
+
+type ModInfo struct {
+	ModID       int    `json:"mod_id"`
+	Game        string `json:"domain_name"`
+	Name        string `json:"name"`
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	Author      string `json:"author"`
+	UploadedBy  string `json:"uploaded_by"`
+	PictureURL  string `json:"picture_url"`
+	CreatedTime int64  `json:"created_timestamp"`
+	Available   bool   `json:"available"`
+}
+
+type File struct {
+	FileID       int    `json:"file_id"`
+	Name         string `json:"name"`
+	FileName     string `json:"file_name"`
+	Version      string `json:"version"`
+	CategoryName string `json:"category_name"`
+	IsPrimary    bool   `json:"is_primary"`
+	Size         int64  `json:"size_in_bytes"`
+	Description  string `json:"description"`
+	Uploaded     int64  `json:"uploaded_timestamp"`
+}
+
+type filesResponse struct {
+	Files []*File `json:"files"`
+}
+
+type downloadLink struct {
+	Name string `json:"name"`
+	URI  string `json:"URI"`
+}
+
+func GetMod(ctx context.Context, l *Link) (m *ModInfo, err error) {
+	err = getJSON(ctx, fmt.Sprintf("/games/%s/mods/%d.json", l.Game, l.ModID), &m)
+	return
+}
+
+// GetFiles is the mod's current files, leaving out those the author archived or marked old
+func GetFiles(ctx context.Context, l *Link) (files []*File, err error) {
+	var r filesResponse
+	if err = getJSON(ctx, fmt.Sprintf("/games/%s/mods/%d/files.json", l.Game, l.ModID), &r); err != nil {
+		return
+	}
+	for _, f := range r.Files {
+		switch f.CategoryName {
+		case "ARCHIVED", "OLD_VERSION", "REMOVED", "":
+			continue
+		}
+		files = append(files, f)
+	}
+	return
+}
+
+// resolve asks the API where the nxm link's file is downloaded from
+func resolve(ctx context.Context, s string) (string, error) {
+	var (
+		l, err = ParseLink(s)
+		links  []*downloadLink
+		p      = ""
+	)
+	if err != nil {
+		return "", err
+	}
+	if l.FileID == 0 {
+		return "", fmt.Errorf("%s does not name a file to download", s)
+	}
+	key := l.key
+	if key.Key == "" {
+		downloadKeysMutex.Lock()
+		key = downloadKeys[fileURL(l.Game, l.ModID, l.FileID)]
+		downloadKeysMutex.Unlock()
+	}
+	if key.Key != "" && (key.Expires == 0 || time.Now().Unix() < key.Expires) {
+		p = fmt.Sprintf("?key=%s&expires=%d", url.QueryEscape(key.Key), key.Expires)
+	}
+	err = getJSON(ctx, fmt.Sprintf("/games/%s/mods/%d/files/%d/download_link.json%s", l.Game, l.ModID, l.FileID, p), &links)
+	var se *browser.StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusForbidden {
+		return "", fmt.Errorf("Nexus Mods only lets premium members download without the website, use the \"Mod Manager Download\" button on the file's page at %s", l.PageURL())
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(links) == 0 {
+		return "", fmt.Errorf("Nexus Mods has no download location for %s", s)
+	}
+	return links[0].URI, nil
+}
+
+func getJSON(ctx context.Context, p string, v interface{}) error {
+	key := config.Get().NexusAPIKey
+	if key == "" {
+		return ErrNoAPIKey
+	}
+	h := http.Header{}
+	h.Set("apikey", key)
+	h.Set("Application-Name", "MoogleModManager")
+	h.Set("Application-Version", browser.Version)
+	err := browser.GetJSON(ctx, apiURL+p, h, v)
+	var se *browser.StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusUnauthorized {
+		return errors.New("Nexus Mods did not accept the API key, check it in File > Configure")
+	}
+	return err
+}
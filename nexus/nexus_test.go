@@ -0,0 +1,176 @@
+package nexus
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serve points the API at a test server answering each path with its response, a status code or a value to encode
+func serve(t *testing.T, responses map[string]interface{}) *[]string {
+	t.Helper()
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.RequestURI())
+		if r.Header.Get("apikey") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		v, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if code, ok := v.(int); ok {
+			w.WriteHeader(code)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(v)
+	}))
+	t.Cleanup(srv.Close)
+	url, key, offline := apiURL, config.Get().NexusAPIKey, config.Get().Offline
+	apiURL, config.Get().NexusAPIKey, config.Get().Offline = srv.URL, "test-key", false
+	t.Cleanup(func() {
+		apiURL, config.Get().NexusAPIKey, config.Get().Offline = url, key, offline
+	})
+	return &requested
+}
+
+func TestParseLink(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want Link
+	}{
+		{s: "https://www.nexusmods.com/finalfantasy6pixelremaster/mods/12", want: Link{Game: "finalfantasy6pixelremaster", ModID: 12}},
+		{s: "https://nexusmods.com/FinalFantasy6PixelRemaster/mods/12?tab=files&file_id=34", want: Link{Game: "finalfantasy6pixelremaster", ModID: 12, FileID: 34}},
+		{s: " nxm://finalfantasy6pixelremaster/mods/12/files/34?key=abc&expires=99 ", want: Link{Game: "finalfantasy6pixelremaster", ModID: 12, FileID: 34, key: downloadKey{Key: "abc", Expires: 99}}},
+	} {
+		l, err := ParseLink(tt.s)
+		if err != nil {
+			t.Errorf("ParseLink(%q): %v", tt.s, err)
+		} else if *l != tt.want {
+			t.Errorf("ParseLink(%q) = %+v, want %+v", tt.s, *l, tt.want)
+		}
+	}
+	for _, s := range []string{
+		"",
+		"https://example.com/finalfantasy6/mods/12",
+		"https://www.nexusmods.com/finalfantasy6pixelremaster/images/12",
+		"https://www.nexusmods.com/finalfantasy6pixelremaster/mods/abc",
+		"nxm://finalfantasy6pixelremaster/mods/12/files/abc",
+		"ftp://www.nexusmods.com/finalfantasy6pixelremaster/mods/12",
+	} {
+		if IsLink(s) {
+			t.Errorf("IsLink(%q) = true", s)
+		}
+	}
+}
+
+func TestGetFilesLeavesOutOldFiles(t *testing.T) {
+	serve(t, map[string]interface{}{
+		"/games/finalfantasy6pixelremaster/mods/12/files.json": filesResponse{Files: []*File{
+			{FileID: 1, Name: "main", CategoryName: "MAIN"},
+			{FileID: 2, Name: "archived", CategoryName: "ARCHIVED"},
+			{FileID: 3, Name: "old", CategoryName: "OLD_VERSION"},
+			{FileID: 4, Name: "removed", CategoryName: "REMOVED"},
+			{FileID: 5, Name: "uncategorized"},
+			{FileID: 6, Name: "optional", CategoryName: "OPTIONAL"},
+		}},
+	})
+	files, err := GetFiles(context.Background(), &Link{Game: "finalfantasy6pixelremaster", ModID: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "main,optional" {
+		t.Errorf("GetFiles = %s, want main,optional", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	requested := serve(t, map[string]interface{}{
+		"/games/finalfantasy6pixelremaster/mods/12/files/34/download_link.json": []*downloadLink{{Name: "CDN", URI: "https://cdn.example.com/file.zip"}},
+		"/games/finalfantasy6pixelremaster/mods/12/files/56/download_link.json": http.StatusForbidden,
+	})
+	u, err := resolve(context.Background(), "nxm://finalfantasy6pixelremaster/mods/12/files/34?key=abc&expires=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != "https://cdn.example.com/file.zip" {
+		t.Errorf("resolve = %s", u)
+	}
+	if r := (*requested)[0]; !strings.HasSuffix(r, "?key=abc&expires=0") {
+		t.Errorf("resolve did not pass the download key: %s", r)
+	}
+
+	_, err = resolve(context.Background(), "nxm://finalfantasy6pixelremaster/mods/12/files/56")
+	if err == nil || !strings.Contains(err.Error(), "premium") || !strings.Contains(err.Error(), "https://www.nexusmods.com/finalfantasy6pixelremaster/mods/12") {
+		t.Errorf("resolve of a forbidden file: %v", err)
+	}
+
+	config.Get().NexusAPIKey = "wrong-key"
+	_, err = resolve(context.Background(), "nxm://finalfantasy6pixelremaster/mods/12/files/34")
+	if err == nil || !strings.Contains(err.Error(), "API key") {
+		t.Errorf("resolve with a wrong API key: %v", err)
+	}
+
+	config.Get().NexusAPIKey = ""
+	if _, err = resolve(context.Background(), "nxm://finalfantasy6pixelremaster/mods/12/files/34"); err != ErrNoAPIKey {
+		t.Errorf("resolve without an API key: %v", err)
+	}
+
+	if _, err = resolve(context.Background(), "nxm://finalfantasy6pixelremaster/mods/12"); err == nil {
+		t.Error("resolve of a link without a file succeeded")
+	}
+}
+
+func TestNewMod(t *testing.T) {
+	var (
+		game, _ = config.FromString("VI")
+		l       = &Link{Game: "finalfantasy6pixelremaster", ModID: 12}
+		info    = &ModInfo{Name: "Test Mod", UploadedBy: "uploader", Version: "1.0", Summary: "A test"}
+		main    = &File{FileID: 34, Name: "Main", Size: 100}
+		alt     = &File{FileID: 56, Name: "Alternate", Description: "Another look", Size: 200}
+	)
+	if _, err := NewMod(l, info, nil); err == nil {
+		t.Error("NewMod without files succeeded")
+	}
+	if _, err := NewMod(&Link{Game: "skyrim", ModID: 12}, info, []*File{main}); err == nil {
+		t.Error("NewMod for a game that is not supported succeeded")
+	}
+
+	m, err := NewMod(l, info, []*File{main})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != "nexus-finalfantasy6pixelremaster-12" || m.Author != "uploader" || m.Link != l.PageURL() {
+		t.Errorf("NewMod = %+v", m)
+	}
+	if len(m.Games) != 1 || m.Games[0].Name != config.GameToName(game) {
+		t.Errorf("NewMod's games = %+v", m.Games)
+	}
+	if len(m.Downloadables) != 1 || m.Downloadables[0].Sources[0] != "nxm://finalfantasy6pixelremaster/mods/12/files/34" {
+		t.Errorf("NewMod's downloads = %+v", m.Downloadables)
+	}
+	if m.DownloadFiles == nil || m.DownloadFiles.DownloadName != "Main" || len(m.Configurations) != 0 {
+		t.Errorf("NewMod with one file does not install it: %+v", m.DownloadFiles)
+	}
+
+	if m, err = NewMod(l, info, []*File{main, alt}); err != nil {
+		t.Fatal(err)
+	}
+	if m.DownloadFiles != nil || len(m.Configurations) != 1 {
+		t.Fatalf("NewMod with several files does not let the user choose: %+v", m)
+	}
+	c := m.Configurations[0]
+	if !c.Root || len(c.Choices) != 2 || c.Choices[1].DownloadFiles.DownloadName != "Alternate" || c.Choices[1].Description != "Another look" {
+		t.Errorf("NewMod's choices = %+v", c.Choices)
+	}
+}
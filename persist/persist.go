@@ -0,0 +1,152 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Generations is how many previous versions of a file Save keeps, as file.1, the newest, to file.<Generations>
+const Generations = 3
+
+// Save replaces the file's content with b so that a crash or a full disk leaves either the old or the new content,
+// never a mix. The previous content is kept as the newest backup generation.
+func Save(file string, b []byte) error {
+	if old, err := ioutil.ReadFile(file); err == nil && bytes.Equal(old, b) {
+		return nil
+	}
+	tmp, err := writeTemp(file, b)
+	if err != nil {
+		return err
+	}
+	if err = rotate(file); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to back up %s: %v", file, err)
+	}
+	return commit(tmp, file)
+}
+
+func SaveJSON(file string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s: %v", file, err)
+	}
+	return Save(file, b)
+}
+
+// WriteFile replaces the file's content with b like Save, without keeping backups
+func WriteFile(file string, b []byte) error {
+	tmp, err := writeTemp(file, b)
+	if err != nil {
+		return err
+	}
+	return commit(tmp, file)
+}
+
+// Load returns the newest of the file and its backups that valid accepts. When that is a backup, the file is restored
+// from it. The error satisfies os.IsNotExist when neither the file nor any backup exists.
+func Load(file string, valid func(b []byte) error) (b []byte, err error) {
+	var firstErr error
+	for i := 0; i <= Generations; i++ {
+		f := generation(file, i)
+		if b, err = ioutil.ReadFile(f); err == nil {
+			if err = valid(b); err == nil {
+				if i > 0 {
+					err = WriteFile(file, b)
+				}
+				return b, err
+			}
+			err = fmt.Errorf("%s is damaged: %v", f, err)
+		}
+		if firstErr == nil || (os.IsNotExist(firstErr) && !os.IsNotExist(err)) {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// LoadJSON decodes the newest of the file and its backups that is valid json into v
+func LoadJSON(file string, v interface{}) error {
+	b, err := Load(file, func(b []byte) error {
+		var i interface{}
+		return json.Unmarshal(b, &i)
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func writeTemp(file string, b []byte) (tmp string, err error) {
+	var f *os.File
+	if f, err = ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*.tmp"); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", file, err)
+	}
+	tmp = f.Name()
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+	if _, err = f.Write(b); err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", file, err)
+	}
+	if err = f.Sync(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", file, err)
+	}
+	if err = f.Chmod(0644); err != nil {
+		return "", err
+	}
+	return tmp, f.Close()
+}
+
+func commit(tmp string, file string) error {
+	if err := os.Rename(tmp, file); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %v", file, err)
+	}
+	syncDir(filepath.Dir(file))
+	return nil
+}
+
+// rotate shifts the backups one generation older and makes the current file the newest, leaving the file in place so
+// it exists at every point of a save
+func rotate(file string) (err error) {
+	if _, err = os.Stat(file); err != nil {
+		return nil
+	}
+	for i := Generations - 1; i >= 1; i-- {
+		if err = os.Rename(generation(file, i), generation(file, i+1)); err != nil && !os.IsNotExist(err) {
+			return
+		}
+	}
+	newest := generation(file, 1)
+	_ = os.Remove(newest)
+	if err = os.Link(file, newest); err != nil {
+		var b []byte
+		if b, err = ioutil.ReadFile(file); err != nil {
+			return
+		}
+		return WriteFile(newest, b)
+	}
+	return nil
+}
+
+func generation(file string, i int) string {
+	if i == 0 {
+		return file
+	}
+	return fmt.Sprintf("%s.%d", file, i)
+}
+
+// syncDir makes a rename in the dir durable, where the platform supports syncing directories
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+}
@@ -0,0 +1,61 @@
+package protocol
+
+import (
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/nexus"
+	"net/url"
+	"strings"
+)
+
+// Scheme links ask the manager to add a mod, moogle://add?url=<mod definition url>&game=<game id>, where the game is
+// optional and is its id or name in games.json, e.g. VI.
+const Scheme = "moogle"
+
+// Request is a mod a link asks to add and install. Game is nil when the link does not say which game it is for, Nexus
+// links always do.
+type Request struct {
+	URL  string
+	Game *config.Game
+}
+
+// Parse reads a moogle or nxm link, or the url of a mod definition or Nexus mod page
+func Parse(link string) (*Request, error) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a link: %v", link, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case Scheme:
+		action := u.Host
+		if action == "" {
+			action = strings.Trim(u.Opaque+u.Path, "/")
+		}
+		if action != "add" {
+			return nil, fmt.Errorf("%s is not a supported action", link)
+		}
+		r := &Request{URL: u.Query().Get("url")}
+		if r.URL == "" {
+			return nil, fmt.Errorf("%s does not have the url of a mod", link)
+		}
+		if g := u.Query().Get("game"); g != "" {
+			game, ok := config.FromString(g)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a known game", g)
+			}
+			r.Game = &game
+		}
+		return r, nil
+	case "nxm", "http", "https":
+		r := &Request{URL: link}
+		if l, err := nexus.ParseLink(link); err == nil {
+			game, err := l.ToGame()
+			if err != nil {
+				return nil, err
+			}
+			r.Game = &game
+		}
+		return r, nil
+	}
+	return nil, fmt.Errorf("%s is not a supported link", link)
+}
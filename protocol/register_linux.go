@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const desktopName = "moogle-mod-manager.desktop"
+
+// Register makes the application the handler of moogle links, and of nxm links when the user asked for them, through
+// a desktop entry that starts it in the directory it is running from now, so a link reaches the instance with the same
+// mods
+func Register() (err error) {
+	var (
+		exe  string
+		home string
+		dir  string
+		b    []byte
+		// schemes are the links the application handles, nxm links are only taken from another mod manager on request
+		schemes = []string{Scheme}
+	)
+	if config.Get().HandleNexusLinks {
+		schemes = append(schemes, "nxm")
+	}
+	if exe, err = os.Executable(); err != nil {
+		return
+	}
+	if home, err = os.UserHomeDir(); err != nil {
+		return
+	}
+	dir = filepath.Join(home, ".local", "share", "applications")
+	if d := os.Getenv("XDG_DATA_HOME"); d != "" {
+		dir = filepath.Join(d, "applications")
+	}
+	entry := fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=Moogle Mod Manager
+Exec="%s" %%u
+Path=%s
+Terminal=false
+NoDisplay=true
+MimeType=%s
+`, exe, config.PWD, mimeTypes(schemes))
+	f := filepath.Join(dir, desktopName)
+	if b, err = ioutil.ReadFile(f); err == nil && string(b) == entry {
+		return nil
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	if err = ioutil.WriteFile(f, []byte(entry), 0644); err != nil {
+		return
+	}
+	for _, s := range schemes {
+		if err = exec.Command("xdg-mime", "default", desktopName, "x-scheme-handler/"+s).Run(); err != nil {
+			return fmt.Errorf("failed to register as the handler of %s links: %v", s, err)
+		}
+	}
+	return nil
+}
+
+func mimeTypes(schemes []string) (s string) {
+	for _, scheme := range schemes {
+		s += "x-scheme-handler/" + scheme + ";"
+	}
+	return
+}
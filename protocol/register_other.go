@@ -0,0 +1,8 @@
+//go:build !linux
+
+package protocol
+
+// Register does nothing, links are only registered on Linux
+func Register() error {
+	return nil
+}
@@ -0,0 +1,6 @@
+package steam
+
+// roots are the dirs Steam may be installed in
+func roots() []string {
+	return []string{expandHome("~/Library/Application Support/Steam")}
+}
@@ -0,0 +1,21 @@
+//go:build !windows && !darwin
+
+package steam
+
+// roots are the dirs Steam may be installed in: the native install, the Flatpak and Snap packages, and Steam for
+// Windows running under Wine or Proton
+func roots() (r []string) {
+	for _, p := range []string{
+		"~/.steam/steam",
+		"~/.steam/root",
+		"~/.local/share/Steam",
+		"~/.var/app/com.valvesoftware.Steam/.local/share/Steam",
+		"~/.var/app/com.valvesoftware.Steam/.steam/steam",
+		"~/snap/steam/common/.local/share/Steam",
+		"~/.wine/drive_c/Program Files (x86)/Steam",
+		"~/.wine/drive_c/Program Files/Steam",
+	} {
+		r = append(r, expandHome(p))
+	}
+	return
+}
@@ -0,0 +1,33 @@
+package steam
+
+import (
+	"golang.org/x/sys/windows/registry"
+	"os"
+	"path/filepath"
+)
+
+// roots are the dirs Steam may be installed in, starting with the one Steam records in the registry
+func roots() (r []string) {
+	for _, k := range []struct {
+		root registry.Key
+		path string
+		name string
+	}{
+		{registry.CURRENT_USER, `Software\Valve\Steam`, "SteamPath"},
+		{registry.LOCAL_MACHINE, `SOFTWARE\WOW6432Node\Valve\Steam`, "InstallPath"},
+		{registry.LOCAL_MACHINE, `SOFTWARE\Valve\Steam`, "InstallPath"},
+	} {
+		if key, err := registry.OpenKey(k.root, k.path, registry.QUERY_VALUE); err == nil {
+			if p, _, err := key.GetStringValue(k.name); err == nil && p != "" {
+				r = append(r, filepath.FromSlash(p))
+			}
+			_ = key.Close()
+		}
+	}
+	for _, env := range []string{"ProgramFiles(x86)", "ProgramFiles"} {
+		if p := os.Getenv(env); p != "" {
+			r = append(r, filepath.Join(p, "Steam"))
+		}
+	}
+	return
+}
@@ -0,0 +1,151 @@
+package steam
+
+import (
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FindGames looks for the defined games in every Steam library, falling back to the install paths in the games'
+// definitions. Games that are not found are left out.
+func FindGames() map[config.Game]string {
+	return findGames(roots())
+}
+
+// Prefill sets the dir of every game that is found and does not have a dir yet. Games that installed tells have mods'
+// files in their current dir are left alone so those files are not lost track of. It returns the games it set.
+func Prefill(installed func(game config.Game) bool) (set []config.Game, err error) {
+	return prefill(FindGames(), installed)
+}
+
+func prefill(found map[config.Game]string, installed func(game config.Game) bool) (set []config.Game, err error) {
+	c := config.Get()
+	for _, game := range config.Games() {
+		dir, ok := found[game]
+		if !ok || c.GameDirs[config.String(game)] != "" || installed(game) {
+			continue
+		}
+		c.SetGameDir(dir, game)
+		set = append(set, game)
+	}
+	if len(set) > 0 {
+		err = config.Save()
+	}
+	return
+}
+
+func findGames(roots []string) map[config.Game]string {
+	var (
+		found = make(map[config.Game]string)
+		libs  []string
+	)
+	for _, r := range roots {
+		libs = append(libs, libraries(r)...)
+	}
+	libs = unique(libs)
+	for _, game := range config.Games() {
+		def := config.GetGameDef(game)
+		if def.SteamAppID != 0 {
+			for _, l := range libs {
+				if dir := installDir(l, def.SteamAppID); dir != "" {
+					found[game] = dir
+					break
+				}
+			}
+		}
+		if _, ok := found[game]; !ok {
+			for _, p := range def.InstallPaths {
+				if p = expandHome(p); exists(p) {
+					found[game] = filepath.Clean(p)
+					break
+				}
+			}
+		}
+	}
+	return found
+}
+
+// libraries are the Steam library dirs listed in libraryfolders.vdf of the Steam install at root, which is one
+// itself. Both the current format, where each library is a document with a path, and the older one, where each
+// library is only its path, are read.
+func libraries(root string) (libs []string) {
+	if !exists(filepath.Join(root, "steamapps")) {
+		return nil
+	}
+	libs = append(libs, root)
+	for _, f := range []string{filepath.Join(root, "steamapps", "libraryfolders.vdf"), filepath.Join(root, "config", "libraryfolders.vdf")} {
+		v, err := readVDF(f)
+		if err != nil {
+			continue
+		}
+		folders := v.get("libraryfolders")
+		keys := make([]string, 0, len(folders))
+		for key := range folders {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			switch lib := folders[key].(type) {
+			case vdf:
+				if p := lib.str("path"); p != "" {
+					libs = append(libs, p)
+				}
+			case string:
+				if _, err = strconv.Atoi(key); err == nil {
+					libs = append(libs, lib)
+				}
+			}
+		}
+	}
+	return
+}
+
+// installDir is where the app is installed in the library, empty when it is not
+func installDir(lib string, appID int) string {
+	v, err := readVDF(filepath.Join(lib, "steamapps", fmt.Sprintf("appmanifest_%d.acf", appID)))
+	if err != nil {
+		return ""
+	}
+	name := v.get("AppState").str("installdir")
+	if name == "" {
+		return ""
+	}
+	if dir := filepath.Join(lib, "steamapps", "common", name); exists(dir) {
+		return dir
+	}
+	return ""
+}
+
+// unique drops libraries listed more than once, including through symlinks such as ~/.steam/steam
+func unique(dirs []string) (u []string) {
+	seen := make(map[string]bool)
+	for _, d := range dirs {
+		key := filepath.Clean(d)
+		if r, err := filepath.EvalSymlinks(key); err == nil {
+			key = r
+		}
+		if !seen[key] {
+			seen[key] = true
+			u = append(u, d)
+		}
+	}
+	return
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return filepath.FromSlash(p)
+}
+
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
@@ -0,0 +1,183 @@
+package steam
+
+import (
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, file string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// installApp puts the app in the library with its manifest, the manifest leaves out installdir when dir is empty
+func installApp(t *testing.T, lib string, appID int, dir string) {
+	t.Helper()
+	manifest := fmt.Sprintf("\"AppState\"\n{\n\t\"appid\"\t\t\"%d\"\n", appID)
+	if dir != "" {
+		manifest += fmt.Sprintf("\t\"installdir\"\t\t\"%s\"\n", dir)
+		if err := os.MkdirAll(filepath.Join(lib, "steamapps", "common", dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(lib, "steamapps", fmt.Sprintf("appmanifest_%d.acf", appID)), manifest+"}\n")
+}
+
+// escape writes the path as Steam does, with its backslashes escaped
+func escape(p string) string {
+	return strings.ReplaceAll(p, `\`, `\\`)
+}
+
+func game(t *testing.T, id string) config.Game {
+	t.Helper()
+	g, ok := config.FromString(id)
+	if !ok {
+		t.Fatalf("%s is not a game", id)
+	}
+	return g
+}
+
+func TestFindGamesInLibraries(t *testing.T) {
+	var (
+		root = t.TempDir()
+		lib  = t.TempDir()
+		vi   = game(t, "VI")
+		i    = game(t, "I")
+	)
+	writeFile(t, filepath.Join(root, "steamapps", "libraryfolders.vdf"), fmt.Sprintf(`"libraryfolders"
+{
+	"0"
+	{
+		"path"		"%s"
+	}
+	"1"
+	{
+		"path"		"%s"
+		"apps"
+		{
+			"%d"		"1"
+		}
+	}
+}
+`, escape(root), escape(lib), config.GetGameDef(vi).SteamAppID))
+	installApp(t, lib, config.GetGameDef(vi).SteamAppID, "FINAL FANTASY VI PR")
+	installApp(t, root, config.GetGameDef(i).SteamAppID, "FINAL FANTASY PR")
+
+	found := findGames([]string{root})
+	for g, want := range map[config.Game]string{
+		vi: filepath.Join(lib, "steamapps", "common", "FINAL FANTASY VI PR"),
+		i:  filepath.Join(root, "steamapps", "common", "FINAL FANTASY PR"),
+	} {
+		if found[g] != want {
+			t.Errorf("findGames found %s at %q, want %q", config.GameNameString(g), found[g], want)
+		}
+	}
+}
+
+func TestFindGamesInOldLibraries(t *testing.T) {
+	var (
+		root = t.TempDir()
+		lib  = t.TempDir()
+		vi   = game(t, "VI")
+	)
+	writeFile(t, filepath.Join(root, "steamapps", "libraryfolders.vdf"), fmt.Sprintf(`"LibraryFolders"
+{
+	"TimeNextStatsReport"		"1234567890"
+	"ContentStatsID"		"-123"
+	"1"		"%s"
+}
+`, escape(lib)))
+	installApp(t, lib, config.GetGameDef(vi).SteamAppID, "FINAL FANTASY VI PR")
+
+	if libs := libraries(root); !reflect.DeepEqual(libs, []string{root, lib}) {
+		t.Errorf("libraries = %v, want %v", libs, []string{root, lib})
+	}
+	found := findGames([]string{root})
+	if dir := found[vi]; dir != filepath.Join(lib, "steamapps", "common", "FINAL FANTASY VI PR") {
+		t.Errorf("findGames found %s at %q", config.GameNameString(vi), dir)
+	}
+}
+
+func TestLibrariesUnescapeWindowsPaths(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "steamapps", "libraryfolders.vdf"), `"libraryfolders"
+{
+	"0"
+	{
+		"path"		"D:\\SteamLibrary"
+	}
+	"1"		"E:\\Games\\Steam Library"
+}
+`)
+	want := []string{root, `D:\SteamLibrary`, `E:\Games\Steam Library`}
+	if libs := libraries(root); !reflect.DeepEqual(libs, want) {
+		t.Errorf("libraries = %q, want %q", libs, want)
+	}
+}
+
+func TestFindGamesSkipsIncompleteInstalls(t *testing.T) {
+	var (
+		root = t.TempDir()
+		vi   = game(t, "VI")
+		v    = game(t, "V")
+	)
+	if err := os.MkdirAll(filepath.Join(root, "steamapps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The manifest has no installdir
+	installApp(t, root, config.GetGameDef(vi).SteamAppID, "")
+	// The manifest names a dir that is not there
+	writeFile(t, filepath.Join(root, "steamapps", fmt.Sprintf("appmanifest_%d.acf", config.GetGameDef(v).SteamAppID)),
+		"\"AppState\"\n{\n\t\"installdir\"\t\t\"FINAL FANTASY V PR\"\n}\n")
+
+	found := findGames([]string{root})
+	for _, g := range []config.Game{vi, v} {
+		if dir, ok := found[g]; ok {
+			t.Errorf("findGames found %s at %s", config.GameNameString(g), dir)
+		}
+	}
+}
+
+func TestFindGamesWithoutSteam(t *testing.T) {
+	root := t.TempDir()
+	if libs := libraries(root); len(libs) != 0 {
+		t.Errorf("libraries of a dir without steamapps = %v", libs)
+	}
+	if libs := libraries(filepath.Join(root, "missing")); len(libs) != 0 {
+		t.Errorf("libraries of a missing dir = %v", libs)
+	}
+}
+
+func TestPrefill(t *testing.T) {
+	config.PWD = t.TempDir()
+	dirs := config.Get().GameDirs
+	config.Get().GameDirs = map[string]string{"I": "/configured/I"}
+	t.Cleanup(func() { config.Get().GameDirs = dirs })
+	found := map[config.Game]string{
+		game(t, "I"):  "/found/I",
+		game(t, "II"): "/found/II",
+		game(t, "VI"): "/found/VI",
+	}
+	set, err := prefill(found, func(g config.Game) bool { return g == game(t, "II") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []config.Game{game(t, "VI")}; !reflect.DeepEqual(set, want) {
+		t.Errorf("prefill set %v, want %v", set, want)
+	}
+	want := map[string]string{"I": "/configured/I", "VI": "/found/VI"}
+	if got := config.Get().GameDirs; !reflect.DeepEqual(got, want) {
+		t.Errorf("the game dirs are %v, want %v", got, want)
+	}
+}
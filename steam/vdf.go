@@ -0,0 +1,165 @@
+package steam
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// vdf is a document in Valve's KeyValues format, which libraryfolders.vdf and the appmanifest_*.acf files are written
+// in. Values are either strings or nested documents. Keys are kept lower case as Steam does not keep their case
+// consistent.
+type vdf map[string]interface{}
+
+func readVDF(file string) (vdf, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseVDF(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	return v, nil
+}
+
+func parseVDF(s string) (vdf, error) {
+	p := &vdfParser{s: s}
+	v, err := p.parse(false)
+	if err != nil {
+		return nil, fmt.Errorf("%v at line %d", err, p.line+1)
+	}
+	return v, nil
+}
+
+// get is the document under the key, nil when there is none
+func (v vdf) get(key string) vdf {
+	d, _ := v[strings.ToLower(key)].(vdf)
+	return d
+}
+
+// str is the string under the key, empty when there is none
+func (v vdf) str(key string) string {
+	s, _ := v[strings.ToLower(key)].(string)
+	return s
+}
+
+type vdfParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (p *vdfParser) parse(nested bool) (v vdf, err error) {
+	var (
+		key, value string
+		quoted     bool
+	)
+	v = make(vdf)
+	for {
+		if key, quoted, err = p.token(); err != nil {
+			return
+		}
+		switch {
+		case key == "" && !quoted:
+			if nested {
+				return nil, errors.New("unexpected end of file")
+			}
+			return
+		case key == "}" && !quoted:
+			if !nested {
+				return nil, errors.New("unexpected }")
+			}
+			return
+		case key == "{" && !quoted:
+			return nil, errors.New("unexpected {")
+		}
+		if value, quoted, err = p.token(); err != nil {
+			return
+		}
+		if value == "{" && !quoted {
+			if v[strings.ToLower(key)], err = p.parse(true); err != nil {
+				return
+			}
+		} else if (value == "}" || value == "") && !quoted {
+			return nil, fmt.Errorf("%s has no value", key)
+		} else {
+			v[strings.ToLower(key)] = value
+		}
+		p.skipCondition()
+	}
+}
+
+// token is the next string, brace or empty at the end of the file
+func (p *vdfParser) token() (t string, quoted bool, err error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return "", false, nil
+	}
+	switch c := p.s[p.pos]; c {
+	case '{', '}':
+		p.pos++
+		return string(c), false, nil
+	case '"':
+		p.pos++
+		sb := strings.Builder{}
+		for p.pos < len(p.s) {
+			c = p.s[p.pos]
+			p.pos++
+			switch c {
+			case '"':
+				return sb.String(), true, nil
+			case '\\':
+				if p.pos < len(p.s) {
+					c = p.s[p.pos]
+					p.pos++
+					switch c {
+					case 'n':
+						c = '\n'
+					case 't':
+						c = '\t'
+					}
+				}
+			case '\n':
+				p.line++
+			}
+			sb.WriteByte(c)
+		}
+		return "", false, errors.New("unterminated string")
+	}
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" \t\r\n{}\"", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos], false, nil
+}
+
+// skipSpace skips white space and // comments
+func (p *vdfParser) skipSpace() {
+	for p.pos < len(p.s) {
+		switch c := p.s[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case strings.HasPrefix(p.s[p.pos:], "//"):
+			for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// skipCondition skips a platform condition such as [$WIN32] following a value
+func (p *vdfParser) skipCondition() {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == '[' {
+		if i := strings.IndexByte(p.s[p.pos:], ']'); i >= 0 {
+			p.pos += i + 1
+		}
+	}
+}
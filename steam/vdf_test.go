@@ -0,0 +1,66 @@
+package steam
+
+import (
+	"testing"
+)
+
+func TestParseVDF(t *testing.T) {
+	v, err := parseVDF(`// written by Steam
+"LibraryFolders"
+{
+	"contentstatsid"		"-123"
+	"0"
+	{
+		"Path"		"D:\\SteamLibrary"
+		"label"		"Games \"main\""
+		"apps"
+		{
+			"1173820"		"123456"
+		}
+	}
+	"1"		"E:\\Games\\Steam"	[$WIN32]
+	unquoted	value
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	folders := v.get("libraryfolders")
+	if folders == nil {
+		t.Fatal("libraryfolders is missing")
+	}
+	for _, tt := range []struct {
+		got, want string
+	}{
+		{got: folders.str("ContentStatsID"), want: "-123"},
+		{got: folders.get("0").str("path"), want: `D:\SteamLibrary`},
+		{got: folders.get("0").str("label"), want: `Games "main"`},
+		{got: folders.get("0").get("apps").str("1173820"), want: "123456"},
+		{got: folders.str("1"), want: `E:\Games\Steam`},
+		{got: folders.str("unquoted"), want: "value"},
+		{got: folders.str("missing"), want: ""},
+		{got: folders.str("0"), want: ""},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+	if folders.get("1") != nil {
+		t.Error("a string was read as a document")
+	}
+}
+
+func TestParseVDFErrors(t *testing.T) {
+	for _, s := range []string{
+		`"a" { "b" "c"`,
+		`"a" "b" }`,
+		`{ "a" "b" }`,
+		`"a"`,
+		`"a" { "b" }`,
+		`"a" "unterminated`,
+	} {
+		if _, err := parseVDF(s); err == nil {
+			t.Errorf("parseVDF(%q) succeeded", s)
+		}
+	}
+}
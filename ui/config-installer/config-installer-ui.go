@@ -1,12 +1,16 @@
 package config_installer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"github.com/kiamev/moogle-mod-manager/browser"
 	"github.com/kiamev/moogle-mod-manager/mods"
+	"github.com/kiamev/moogle-mod-manager/mods/managed"
 	"github.com/kiamev/moogle-mod-manager/ui/state"
 	"github.com/kiamev/moogle-mod-manager/ui/util"
 )
@@ -82,7 +86,17 @@ func (i *configInstallerUI) Draw(w fyne.Window) {
 				if i.isSandbox {
 					util.DisplayDownloadsAndFiles(i.mod, i.toInstall)
 				} else {
-					// TODO
+					dm := managed.NewDownloadManager()
+					util.ShowDownloadProgress("Enabling "+i.mod.Name, dm,
+						func(ctx context.Context, progress func(string, float64), downloads func(browser.DownloadProgress)) error {
+							return managed.EnableMod(ctx, *state.CurrentGame, i.mod.ID, i.toInstall, dm, progress, downloads)
+						},
+						func(err error) {
+							if err != nil && !errors.Is(err, context.Canceled) {
+								dialog.ShowError(err, state.Window)
+							}
+							state.ShowPreviousScreen()
+						})
 				}
 			} else {
 				for _, i.currentConfig = range i.mod.Configurations {
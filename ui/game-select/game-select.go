@@ -19,33 +19,17 @@ func (s *GameSelect) OnClose() {
 }
 
 func (s *GameSelect) Draw(w fyne.Window) {
+	buttons := container.NewVBox(
+		widget.NewLabelWithStyle("Select Games", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	for _, g := range config.Games() {
+		game := g
+		buttons.Add(widget.NewButton(config.GameNameString(game), func() {
+			state.CurrentGame = toGamePtr(game)
+			state.ShowScreen(state.LocalMods)
+		}))
+	}
 	w.SetContent(container.NewGridWithColumns(2,
-		container.NewVBox(
-			widget.NewLabelWithStyle("Select Games", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-			widget.NewButton("Final Fantasy I", func() {
-				state.CurrentGame = toGamePtr(config.I)
-				state.ShowScreen(state.LocalMods)
-			}),
-			widget.NewButton("Final Fantasy II", func() {
-				state.CurrentGame = toGamePtr(config.II)
-				state.ShowScreen(state.LocalMods)
-			}),
-			widget.NewButton("Final Fantasy III", func() {
-				state.CurrentGame = toGamePtr(config.III)
-				state.ShowScreen(state.LocalMods)
-			}),
-			widget.NewButton("Final Fantasy IV", func() {
-				state.CurrentGame = toGamePtr(config.IV)
-				state.ShowScreen(state.LocalMods)
-			}),
-			widget.NewButton("Final Fantasy V", func() {
-				state.CurrentGame = toGamePtr(config.V)
-				state.ShowScreen(state.LocalMods)
-			}),
-			widget.NewButton("Final Fantasy VI", func() {
-				state.CurrentGame = toGamePtr(config.VI)
-				state.ShowScreen(state.LocalMods)
-			})),
+		buttons,
 		container.NewVBox(),
 	))
 }
@@ -0,0 +1,55 @@
+package links
+
+import (
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/protocol"
+	"github.com/kiamev/moogle-mod-manager/ui/local"
+	"github.com/kiamev/moogle-mod-manager/ui/state"
+)
+
+// Open adds the mods of links passed on the command line, either to this launch or to a later one that forwarded
+// them. The mod is added to the game the link is for. When a link does not say, the current game is used, otherwise the
+// user picks one.
+func Open(args []string) {
+	for _, a := range args {
+		r, err := protocol.Parse(a)
+		if err != nil {
+			dialog.ShowError(err, state.Window)
+			continue
+		}
+		if r.Game != nil {
+			open(*r.Game, r.URL)
+		} else if state.CurrentGame != nil {
+			open(*state.CurrentGame, r.URL)
+		} else {
+			selectGame(r.URL)
+		}
+	}
+}
+
+func open(game config.Game, url string) {
+	state.CurrentGame = &game
+	if state.GetCurrentGUI() != state.LocalMods {
+		state.ShowScreen(state.LocalMods)
+	} else {
+		state.GetScreen(state.LocalMods).Draw(state.Window)
+	}
+	state.GetScreen(state.LocalMods).(local.LocalUI).Open(url)
+}
+
+func selectGame(url string) {
+	var games []string
+	for _, g := range config.Games() {
+		games = append(games, config.GameNameString(g))
+	}
+	s := widget.NewSelect(games, func(string) {})
+	dialog.ShowForm("Which game is the mod for?", "Add", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Game", s)},
+		func(ok bool) {
+			if game, found := config.FromString(s.Selected); ok && found {
+				open(game, url)
+			}
+		}, state.Window)
+}
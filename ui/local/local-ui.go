@@ -1,22 +1,31 @@
 package local
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"github.com/kiamev/moogle-mod-manager/browser"
 	"github.com/kiamev/moogle-mod-manager/config"
 	"github.com/kiamev/moogle-mod-manager/mods"
+	"github.com/kiamev/moogle-mod-manager/mods/io"
 	"github.com/kiamev/moogle-mod-manager/mods/managed"
 	"github.com/kiamev/moogle-mod-manager/mods/managed/model"
+	config_installer "github.com/kiamev/moogle-mod-manager/ui/config-installer"
 	cw "github.com/kiamev/moogle-mod-manager/ui/custom-widgets"
 	"github.com/kiamev/moogle-mod-manager/ui/state"
+	"github.com/kiamev/moogle-mod-manager/ui/util"
 	"github.com/ncruces/zenity"
+	"strings"
 )
 
 type LocalUI interface {
 	state.Screen
 	GetSelected() *model.TrackedMod
+	Open(url string)
 }
 
 func New() LocalUI {
@@ -58,20 +67,40 @@ func (m *localMods) Draw(w fyne.Window) {
 			}))
 		removeButton = widget.NewButton("Remove", func() {
 			if m.selectedMod != nil {
-				if err := managed.RemoveMod(*state.CurrentGame, m.selectedMod.GetModID()); err != nil {
-					dialog.ShowError(err, state.Window)
-					return
-				}
-				m.selectedMod = nil
-				m.Draw(w)
+				m.remove(m.selectedMod, func() {
+					m.Draw(w)
+				})
+			}
+		})
+		enableButton = widget.NewButton("Enable", nil)
+		verifyButton = widget.NewButton("Verify", func() {
+			if m.selectedMod != nil {
+				m.verify(m.selectedMod)
 			}
 		})
 		modDetails = container.NewScroll(container.NewMax())
 	)
+	enableButton.OnTapped = func() {
+		if m.selectedMod != nil {
+			m.toggleEnabled(m.selectedMod, func() {
+				m.Draw(w)
+			})
+		}
+	}
 	removeButton.Disable()
+	enableButton.Disable()
+	verifyButton.Disable()
 	modList.OnSelected = func(id widget.ListItemID) {
 		m.selectedMod = selectable[id]
 		removeButton.Enable()
+		enableButton.Enable()
+		if m.selectedMod.IsEnabled() {
+			enableButton.SetText("Disable")
+			verifyButton.Enable()
+		} else {
+			enableButton.SetText("Enable")
+			verifyButton.Disable()
+		}
 		modDetails.Content = container.NewCenter(widget.NewLabel("Loading..."))
 		modDetails.Refresh()
 		modDetails.Content = m.createPreview(m.selectedMod.Mod)
@@ -80,10 +109,12 @@ func (m *localMods) Draw(w fyne.Window) {
 	modList.OnUnselected = func(id widget.ListItemID) {
 		m.selectedMod = nil
 		removeButton.Disable()
+		enableButton.Disable()
+		verifyButton.Disable()
 		modDetails.Hide()
 	}
 
-	buttons := container.NewHBox(addButton, widget.NewSeparator(), removeButton)
+	buttons := container.NewHBox(addButton, widget.NewSeparator(), enableButton, verifyButton, removeButton)
 
 	split := container.NewHSplit(
 		modList,
@@ -155,6 +186,124 @@ func (m *localMods) createCompatibility(compatibility *mods.ModCompatibility) fy
 	return c
 }
 
+func (m *localMods) toggleEnabled(tm *model.TrackedMod, callback func()) {
+	if tm.IsEnabled() {
+		m.disable(tm, callback)
+		return
+	}
+	if len(tm.Mod.Configurations) > 0 {
+		ci := state.GetScreen(state.ConfigInstaller).(config_installer.ConfigInstaller)
+		if err := ci.Setup(tm.Mod, false, tm.GetDir()); err != nil {
+			dialog.ShowError(err, state.Window)
+			return
+		}
+		state.ShowScreen(state.ConfigInstaller)
+		return
+	}
+	dm := managed.NewDownloadManager()
+	util.ShowDownloadProgress("Enabling "+tm.Mod.Name, dm,
+		func(ctx context.Context, progress func(string, float64), downloads func(browser.DownloadProgress)) error {
+			return managed.EnableMod(ctx, *state.CurrentGame, tm.GetModID(), nil, dm, progress, downloads)
+		},
+		func(err error) {
+			if err != nil && !errors.Is(err, context.Canceled) {
+				dialog.ShowError(err, state.Window)
+			}
+			callback()
+		})
+}
+
+func (m *localMods) disable(tm *model.TrackedMod, callback func()) {
+	m.confirmRevert(tm, "Disable", func() {
+		if err := managed.DisableMod(*state.CurrentGame, tm.GetModID()); err != nil {
+			dialog.ShowError(err, state.Window)
+		}
+		callback()
+	})
+}
+
+func (m *localMods) remove(tm *model.TrackedMod, callback func()) {
+	remove := func() {
+		if err := managed.RemoveMod(*state.CurrentGame, tm.GetModID()); err != nil {
+			dialog.ShowError(err, state.Window)
+			return
+		}
+		m.selectedMod = nil
+		callback()
+	}
+	if !tm.IsEnabled() {
+		remove()
+		return
+	}
+	m.confirmRevert(tm, "Remove", remove)
+}
+
+// confirmRevert runs revert, which takes the mod's files out of the game, once the user agrees to it when any of the
+// files were changed since the mod was enabled
+func (m *localMods) confirmRevert(tm *model.TrackedMod, action string, revert func()) {
+	changes, err := managed.VerifyMod(*state.CurrentGame, tm.GetModID())
+	if err != nil {
+		dialog.ShowError(err, state.Window)
+		return
+	}
+	if len(changes) == 0 {
+		revert()
+		return
+	}
+	dialog.ShowConfirm("Files Changed",
+		fmt.Sprintf("These files were changed since %s was enabled:\n%s\n%s anyway?", tm.Mod.Name, changesToString(changes), action),
+		func(ok bool) {
+			if ok {
+				revert()
+			}
+		}, state.Window)
+}
+
+func (m *localMods) verify(tm *model.TrackedMod) {
+	changes, err := managed.VerifyMod(*state.CurrentGame, tm.GetModID())
+	if err != nil {
+		dialog.ShowError(err, state.Window)
+	} else if len(changes) == 0 {
+		dialog.ShowInformation("Verify", "All files match", state.Window)
+	} else {
+		dialog.ShowInformation("Verify", "These files were changed since the mod was enabled by the game, another tool or by hand:\n"+changesToString(changes), state.Window)
+	}
+}
+
+func changesToString(changes []*io.FileChange) string {
+	sb := strings.Builder{}
+	for _, c := range changes {
+		sb.WriteString(fmt.Sprintf("- %s\n", c))
+	}
+	return sb.String()
+}
+
+// Open adds the mod at the url to the current game and offers to enable it, for links opened from outside the manager
+func (m *localMods) Open(url string) {
+	var tm *model.TrackedMod
+	util.ShowProgress("Adding mod",
+		func(ctx context.Context, progress func(string, float64)) (err error) {
+			progress("Adding "+url, -1)
+			tm, err = managed.AddModFromUrl(*state.CurrentGame, url)
+			return
+		},
+		func(err error) {
+			if err != nil {
+				dialog.ShowError(err, state.Window)
+				return
+			}
+			m.selectedMod = tm
+			m.Draw(state.Window)
+			dialog.ShowConfirm("Enable Mod", fmt.Sprintf("Enable %s now?", tm.Mod.Name), func(ok bool) {
+				if ok {
+					m.toggleEnabled(tm, func() {
+						m.Draw(state.Window)
+					})
+				}
+			}, state.Window)
+		})
+}
+
 func (m *localMods) addFromFile() {
 	if file, err := zenity.SelectFile(
 		zenity.Title("Select a mod file"),
@@ -175,7 +324,7 @@ func (m *localMods) addFromUrl(callback func()) {
 		[]*widget.FormItem{widget.NewFormItem("URL", e)},
 		func(ok bool) {
 			if ok && e.Text != "" {
-				if err := managed.AddModFromUrl(*state.CurrentGame, e.Text); err != nil {
+				if _, err := managed.AddModFromUrl(*state.CurrentGame, e.Text); err != nil {
 					dialog.ShowError(err, state.Window)
 					return
 				}
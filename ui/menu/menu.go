@@ -4,10 +4,15 @@ import (
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
 	"github.com/kiamev/moogle-mod-manager/browser"
+	"github.com/kiamev/moogle-mod-manager/config"
+	"github.com/kiamev/moogle-mod-manager/protocol"
 	"github.com/kiamev/moogle-mod-manager/ui/local"
 	a "github.com/kiamev/moogle-mod-manager/ui/mod-author"
 	"github.com/kiamev/moogle-mod-manager/ui/state"
+	"strconv"
+	"strings"
 )
 
 func New() state.Screen {
@@ -38,6 +43,7 @@ func (m *MainMenu) Draw(w fyne.Window) {
 			widget.NewButton("Light", func() {
 				a.Settings().SetTheme(theme.LightTheme())
 			}),*/
+			showConfigure(w)
 		}),
 		fyne.NewMenuItem("Check For Updates", func() {
 			if newer, newerVersion, err := browser.CheckForUpdate(); err != nil {
@@ -85,3 +91,47 @@ func (m *MainMenu) Draw(w fyne.Window) {
 	menus = append(menus, author)
 	w.SetMainMenu(fyne.NewMainMenu(menus...))
 }
+
+func showConfigure(w fyne.Window) {
+	var (
+		c         = config.Get()
+		proxy     = widget.NewEntry()
+		cacheSize = widget.NewEntry()
+		offline   = widget.NewCheck("Offline", func(bool) {})
+		nexusKey  = widget.NewPasswordEntry()
+		nxm       = widget.NewCheck("Handle Nexus Mods links", func(bool) {})
+	)
+	proxy.SetPlaceHolder("http://host:port")
+	proxy.SetText(c.Proxy)
+	if c.CacheSize > 0 {
+		cacheSize.SetText(strconv.Itoa(c.CacheSize))
+	}
+	offline.SetChecked(c.Offline)
+	nexusKey.SetText(c.NexusAPIKey)
+	nxm.SetChecked(c.HandleNexusLinks)
+	d := dialog.NewForm("Configure", "Save", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Proxy", proxy),
+		widget.NewFormItem("Download Cache (MB)", cacheSize),
+		widget.NewFormItem("", offline),
+		widget.NewFormItem("Nexus Mods API Key", nexusKey),
+		widget.NewFormItem("", nxm),
+	}, func(ok bool) {
+		if ok {
+			c.Proxy = strings.TrimSpace(proxy.Text)
+			c.CacheSize, _ = strconv.Atoi(strings.TrimSpace(cacheSize.Text))
+			c.Offline = offline.Checked
+			c.NexusAPIKey = strings.TrimSpace(nexusKey.Text)
+			register := nxm.Checked && !c.HandleNexusLinks
+			c.HandleNexusLinks = nxm.Checked
+			if err := config.Save(); err != nil {
+				dialog.ShowError(err, w)
+			} else if register {
+				if err = protocol.Register(); err != nil {
+					dialog.ShowError(err, w)
+				}
+			}
+		}
+	}, w)
+	d.Resize(fyne.NewSize(400, 250))
+	d.Show()
+}
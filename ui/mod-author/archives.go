@@ -0,0 +1,89 @@
+package mod_author
+
+import (
+	"fmt"
+	"github.com/kiamev/moogle-mod-manager/decompressor"
+	"github.com/kiamev/moogle-mod-manager/mods"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// validateArchives checks every From path of the mod's files and dirs against the local copy of the download they
+// come from. A download's local copy is the file in the working dir named like one of its sources.
+func validateArchives(mod *mods.Mod, baseDir string) (errs string, warnings string) {
+	var (
+		sb       = strings.Builder{}
+		wb       = strings.Builder{}
+		entries  = make(map[string][]decompressor.Entry)
+		checked  = make(map[string]bool)
+		toVerify []*mods.DownloadFiles
+	)
+	if mod.DownloadFiles != nil {
+		toVerify = append(toVerify, mod.DownloadFiles)
+	}
+	for _, c := range mod.Configurations {
+		for _, ch := range c.Choices {
+			if ch.DownloadFiles != nil {
+				toVerify = append(toVerify, ch.DownloadFiles)
+			}
+		}
+	}
+
+	for _, dlf := range toVerify {
+		name := dlf.DownloadName
+		if _, ok := checked[name]; !ok {
+			checked[name] = true
+			if l, err := listDownload(mod, name, baseDir); err != nil {
+				wb.WriteString(fmt.Sprintf("Download [%s] was not checked: %v\n", name, err))
+			} else {
+				entries[name] = l
+			}
+		}
+		if l, ok := entries[name]; ok {
+			for _, m := range decompressor.MissingFiles(l, dlf) {
+				sb.WriteString(fmt.Sprintf("Download [%s] does not contain [%s]\n", name, m))
+			}
+		}
+	}
+	return sb.String(), wb.String()
+}
+
+func listDownload(mod *mods.Mod, name string, baseDir string) ([]decompressor.Entry, error) {
+	var dl *mods.Download
+	for _, d := range mod.Downloadables {
+		if d.Name == name {
+			dl = d
+			break
+		}
+	}
+	if dl == nil {
+		return nil, fmt.Errorf("no downloadable is named %s", name)
+	}
+	f, err := localCopy(dl, baseDir)
+	if err != nil {
+		return nil, err
+	}
+	d, err := decompressor.NewDecompressor(f)
+	if err != nil {
+		return nil, err
+	}
+	return d.List()
+}
+
+// localCopy is the file in the working dir named like one of the download's sources
+func localCopy(dl *mods.Download, baseDir string) (string, error) {
+	for _, s := range dl.Sources {
+		u, err := url.Parse(s)
+		if err != nil {
+			continue
+		}
+		f := path.Join(baseDir, path.Base(u.Path))
+		if fi, err := os.Stat(f); err != nil || fi.IsDir() {
+			continue
+		}
+		return f, nil
+	}
+	return "", fmt.Errorf("no local copy found in the working dir")
+}
@@ -303,9 +303,14 @@ func (a *ModAuthorer) saveFile(asJson bool) {
 }
 
 func (a *ModAuthorer) validate() {
-	s := a.compileMod().Validate()
+	mod := a.compileMod()
+	s := mod.Validate()
+	errs, warnings := validateArchives(mod, state.GetBaseDir())
+	s += errs
 	if s != "" {
-		dialog.ShowError(errors.New(s), state.Window)
+		dialog.ShowError(errors.New(s+warnings), state.Window)
+	} else if warnings != "" {
+		dialog.ShowInformation("", "Mod is valid\n\n"+warnings, state.Window)
 	} else {
 		dialog.ShowInformation("", "Mod is valid", state.Window)
 	}
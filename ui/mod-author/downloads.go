@@ -5,9 +5,11 @@ import (
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"github.com/kiamev/moogle-mod-manager/browser"
 	"github.com/kiamev/moogle-mod-manager/mods"
 	cw "github.com/kiamev/moogle-mod-manager/ui/custom-widgets"
 	"github.com/kiamev/moogle-mod-manager/ui/state"
+	"strconv"
 	"strings"
 )
 
@@ -57,16 +59,23 @@ func (d *downloadsDef) createItem(item interface{}, done ...func(interface{})) {
 	d.createFormItem("Name", m.Name)
 	d.createFormMultiLine("Sources", strings.Join(m.Sources, "\n"))
 	d.createFormSelect("Install Type", mods.InstallTypes, string(m.InstallType))
+	d.createFormItem("SHA256", m.SHA256)
+	d.createFormItem("Size", sizeToString(m.Size))
 
 	fd := dialog.NewForm("Edit Downloadable", "Save", "Cancel", []*widget.FormItem{
 		d.getFormItem("Name"),
 		d.getFormItem("Sources"),
 		d.getFormItem("Install Type"),
+		d.getFormItem("SHA256"),
+		d.getFormItem("Size"),
+		widget.NewFormItem("", widget.NewButton("Compute From Local File", d.computeChecksum)),
 	}, func(ok bool) {
 		if ok {
 			m.Name = d.getString("Name")
 			m.Sources = d.getStrings("Sources", "\n")
 			m.InstallType = mods.InstallType(d.getString("Install Type"))
+			m.SHA256 = strings.ToLower(d.getString("SHA256"))
+			m.Size, _ = strconv.ParseInt(d.getString("Size"), 10, 64)
 			if len(done) > 0 {
 				done[0](m)
 			}
@@ -76,6 +85,29 @@ func (d *downloadsDef) createItem(item interface{}, done ...func(interface{})) {
 	fd.Show()
 }
 
+// computeChecksum fills in the SHA256 and Size from the local copy of the download in the working dir
+func (d *downloadsDef) computeChecksum() {
+	f, err := localCopy(&mods.Download{Sources: d.getStrings("Sources", "\n")}, state.GetBaseDir())
+	if err != nil {
+		dialog.ShowError(err, state.Window)
+		return
+	}
+	sha, size, err := browser.Checksum(f)
+	if err != nil {
+		dialog.ShowError(err, state.Window)
+		return
+	}
+	d.createFormItem("SHA256", sha)
+	d.createFormItem("Size", sizeToString(size))
+}
+
+func sizeToString(size int64) string {
+	if size == 0 {
+		return ""
+	}
+	return strconv.FormatInt(size, 10)
+}
+
 func (d *downloadsDef) draw() fyne.CanvasObject {
 	return container.NewVBox(container.NewHBox(
 		widget.NewLabelWithStyle("Downloadables", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
@@ -54,15 +54,18 @@ func (d *gamesDef) editItem(item interface{}) {
 }
 
 func (d *gamesDef) createItem(item interface{}, done ...func(interface{})) {
-	m := item.(*mods.Game)
-	d.createFormSelect("Games", []string{
-		config.GameNameString(config.I),
-		config.GameNameString(config.II),
-		config.GameNameString(config.III),
-		config.GameNameString(config.IV),
-		config.GameNameString(config.V),
-		config.GameNameString(config.VI),
-	}, config.String(config.NameToGame(m.Name)))
+	var (
+		m     = item.(*mods.Game)
+		names []string
+		name  string
+	)
+	for _, g := range config.Games() {
+		names = append(names, config.GameNameString(g))
+	}
+	if g, ok := config.NameToGame(m.Name); ok {
+		name = config.GameNameString(g)
+	}
+	d.createFormSelect("Games", names, name)
 	var v string
 	versions := m.Versions
 	if versions != nil {
@@ -75,7 +78,9 @@ func (d *gamesDef) createItem(item interface{}, done ...func(interface{})) {
 		d.getFormItem("Versions"),
 	}, func(ok bool) {
 		if ok {
-			m.Name = config.GameToName(config.FromString(d.getString("Games")))
+			if g, found := config.FromString(d.getString("Games")); found {
+				m.Name = config.GameToName(g)
+			}
 			m.Versions = d.getStrings("Versions", ",")
 			if len(done) > 0 {
 				done[0](m)
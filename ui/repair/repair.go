@@ -0,0 +1,46 @@
+package repair
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/kiamev/moogle-mod-manager/mods/managed"
+	"github.com/kiamev/moogle-mod-manager/ui/state"
+	"strings"
+)
+
+const title = "Repair Installed Mods"
+
+// Show reports where the installed mods and the games' files disagree, offering to repair them
+func Show() {
+	found := managed.Issues()
+	if len(found) == 0 {
+		return
+	}
+	sb := strings.Builder{}
+	sb.WriteString("The installed mods do not match the games' files:\n")
+	for _, i := range found {
+		sb.WriteString("\n- " + i.String() + "\n    " + i.Fix() + "\n")
+	}
+	l := widget.NewLabel(sb.String())
+	l.Wrapping = fyne.TextWrapWord
+	d := dialog.NewCustomConfirm(title, "Repair", "Ignore", container.NewVScroll(l), func(ok bool) {
+		if !ok {
+			return
+		}
+		err := managed.Repair(found)
+		if state.GetCurrentGUI() == state.LocalMods {
+			state.GetScreen(state.LocalMods).Draw(state.Window)
+		}
+		if err != nil {
+			dialog.ShowError(err, state.Window)
+		} else if len(managed.Issues()) > 0 {
+			Show()
+		} else {
+			dialog.ShowInformation(title, "The installed mods match the games' files", state.Window)
+		}
+	}, state.Window)
+	d.Resize(fyne.NewSize(600, 400))
+	d.Show()
+}
@@ -0,0 +1,90 @@
+package util
+
+import (
+	"context"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/kiamev/moogle-mod-manager/browser"
+	"github.com/kiamev/moogle-mod-manager/ui/state"
+	"strings"
+)
+
+// ShowProgress runs work in the background behind a dialog showing its progress. Closing the dialog cancels work's
+// context. done is called with work's result once it returns.
+func ShowProgress(title string, work func(ctx context.Context, progress func(status string, done float64)) error, done func(err error)) {
+	showProgress(title, nil, work, done)
+}
+
+// ShowDownloadProgress is ShowProgress for work that downloads with dm. The dialog also lists the progress of each
+// download and pauses and resumes dm.
+func ShowDownloadProgress(title string, dm *browser.DownloadManager, work func(ctx context.Context, progress func(status string, done float64), downloads func(browser.DownloadProgress)) error, done func(err error)) {
+	var (
+		items = widget.NewLabel("")
+		pause = widget.NewButton("Pause", nil)
+	)
+	pause.OnTapped = func() {
+		if dm.IsPaused() {
+			dm.Resume()
+			pause.SetText("Pause")
+		} else {
+			dm.Pause()
+			pause.SetText("Resume")
+		}
+	}
+	items.Hide()
+	pause.Hide()
+	showProgress(title, container.NewVBox(items, container.NewHBox(pause)),
+		func(ctx context.Context, progress func(status string, done float64)) error {
+			return work(ctx, progress, func(p browser.DownloadProgress) {
+				var (
+					lines   = make([]string, len(p.Items))
+					running = false
+				)
+				for i, ip := range p.Items {
+					lines[i] = ip.String()
+					running = running || !ip.Done
+				}
+				items.SetText(strings.Join(lines, "\n"))
+				items.Show()
+				if running {
+					pause.Show()
+				} else {
+					pause.Hide()
+				}
+			})
+		}, done)
+}
+
+func showProgress(title string, extra fyne.CanvasObject, work func(ctx context.Context, progress func(status string, done float64)) error, done func(err error)) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		status      = widget.NewLabel("")
+		bar         = widget.NewProgressBar()
+		infinite    = widget.NewProgressBarInfinite()
+		content     = container.NewVBox(status, bar, infinite)
+	)
+	if extra != nil {
+		content.Add(extra)
+	}
+	d := dialog.NewCustom(title, "Cancel", content, state.Window)
+	bar.Hide()
+	d.SetOnClosed(cancel)
+	d.Show()
+	go func() {
+		err := work(ctx, func(s string, f float64) {
+			status.SetText(s)
+			if f < 0 {
+				bar.Hide()
+				infinite.Show()
+			} else {
+				infinite.Hide()
+				bar.Show()
+				bar.SetValue(f)
+			}
+		})
+		d.Hide()
+		done(err)
+	}()
+}
@@ -11,7 +11,7 @@ import (
 
 func DisplayDownloadsAndFiles(mod *mods.Mod, toInstall []*mods.DownloadFiles) {
 	sb := strings.Builder{}
-	for dl, dlf := range compileDownloadFiles(mod, toInstall) {
+	for dl, dlf := range mod.CompileDownloadFiles(toInstall) {
 		sb.WriteString(fmt.Sprintf("Download: %s\n\n", dl.Name))
 		sb.WriteString("  Sources:\n\n")
 		for _, s := range dl.Sources {
@@ -30,40 +30,3 @@ func DisplayDownloadsAndFiles(mod *mods.Mod, toInstall []*mods.DownloadFiles) {
 	dialog.ShowCustom("Downloads and File/Dir Copies", "ok", widget.NewRichTextFromMarkdown(sb.String()), state.Window)
 	state.ShowPreviousScreen()
 }
-
-func compileDownloadFiles(mod *mods.Mod, toInstall []*mods.DownloadFiles) map[*mods.Download]*mods.DownloadFiles {
-	dlf := make(map[*mods.Download]*mods.DownloadFiles)
-	dl := make(map[string]*mods.Download)
-	for _, d := range mod.Downloadables {
-		dl[d.Name] = d
-	}
-	add(mod.DownloadFiles, dl, dlf)
-	for _, ti := range toInstall {
-		add(ti, dl, dlf)
-	}
-	return dlf
-}
-
-func add(ti *mods.DownloadFiles, dl map[string]*mods.Download, dlf map[*mods.Download]*mods.DownloadFiles) {
-	var (
-		d  *mods.Download
-		f  *mods.DownloadFiles
-		ok bool
-	)
-	if ti == nil {
-		return
-	}
-	if d, ok = dl[ti.DownloadName]; !ok {
-		return
-	}
-	if f, ok = dlf[d]; !ok {
-		f = &mods.DownloadFiles{DownloadName: ti.DownloadName}
-		dlf[d] = f
-	}
-	for _, df := range ti.Files {
-		f.Files = append(f.Files, df)
-	}
-	for _, dd := range ti.Dirs {
-		f.Dirs = append(f.Dirs, dd)
-	}
-}